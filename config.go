@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the server-side settings shared by every session. It is read
+// from a JSON config file; PIXELA_USERNAME and PIXELA_TOKEN override the
+// credentials found there.
+type Config struct {
+	// Username and Token are the default credentials used by tools called
+	// without username/token arguments.
+	Username string `json:"username"`
+	Token    string `json:"token"`
+
+	// Policy restricts the tools listed and callable.
+	Policy
+
+	// Profiles are the named credentials selectable with the profile
+	// argument. They come from their own file, see LoadProfiles.
+	Profiles *ProfileStore `json:"-"`
+}
+
+// defaultConfigPath returns the config file used when neither -config nor
+// PIXELA_MCP_CONFIG is given, e.g. ~/.config/pixela-mcp/config.json.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pixela-mcp", "config.json")
+}
+
+// LoadConfig reads the config file at path, then applies the environment.
+// A missing file is only an error when required is set, i.e. when the path
+// was given explicitly.
+func LoadConfig(path string, required bool) (*Config, error) {
+	config := &Config{Profiles: newProfileStore("")}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, config); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+			}
+		case errors.Is(err, fs.ErrNotExist) && !required:
+		default:
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	if username := os.Getenv("PIXELA_USERNAME"); username != "" {
+		config.Username = username
+	}
+	if token := os.Getenv("PIXELA_TOKEN"); token != "" {
+		config.Token = token
+	}
+
+	return config, nil
+}
+
+// DefaultCredentials returns the configured credentials, if both the
+// username and the token are set.
+func (c *Config) DefaultCredentials() (Credentials, bool) {
+	if c.Username == "" || c.Token == "" {
+		return Credentials{}, false
+	}
+	return Credentials{Username: c.Username, Token: c.Token}, true
+}
+
+// Secrets returns the configured tokens, which are redacted wherever they
+// might surface.
+func (c *Config) Secrets() []string {
+	return append(c.Profiles.Tokens(), c.Token)
+}
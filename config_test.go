@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigCredentials(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"username":"file-user","token":"file-token"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		required     bool
+		envUsername  string
+		envToken     string
+		wantUsername string
+		wantToken    string
+	}{
+		{"config file", path, true, "", "", "file-user", "file-token"},
+		{"environment overrides the file", path, true, "env-user", "env-token", "env-user", "env-token"},
+		{"environment overrides one field", path, true, "", "env-token", "file-user", "env-token"},
+		{"environment without a file", filepath.Join(dir, "missing.json"), false, "env-user", "env-token", "env-user", "env-token"},
+		{"nothing configured", filepath.Join(dir, "missing.json"), false, "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PIXELA_USERNAME", tt.envUsername)
+			t.Setenv("PIXELA_TOKEN", tt.envToken)
+
+			config, err := LoadConfig(tt.path, tt.required)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if config.Username != tt.wantUsername || config.Token != tt.wantToken {
+				t.Errorf("credentials = %q/%q, want %q/%q", config.Username, config.Token, tt.wantUsername, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissingRequiredFile(t *testing.T) {
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"), true); err == nil {
+		t.Error("LoadConfig of a missing required file succeeded, want an error")
+	}
+}
+
+func TestApplyCredentials(t *testing.T) {
+	defaults := Credentials{Username: "alice", Token: "default-token"}
+	tests := []struct {
+		name      string
+		arguments map[string]interface{}
+		want      map[string]interface{}
+	}{
+		{
+			name:      "both filled in",
+			arguments: map[string]interface{}{"graphID": "g1"},
+			want:      map[string]interface{}{"graphID": "g1", "username": "alice", "token": "default-token"},
+		},
+		{
+			name:      "explicit token wins",
+			arguments: map[string]interface{}{"token": "other-token"},
+			want:      map[string]interface{}{"username": "alice", "token": "other-token"},
+		},
+		{
+			name:      "same user",
+			arguments: map[string]interface{}{"username": "alice"},
+			want:      map[string]interface{}{"username": "alice", "token": "default-token"},
+		},
+		{
+			name:      "no token for another user",
+			arguments: map[string]interface{}{"username": "bob"},
+			want:      map[string]interface{}{"username": "bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyCredentials(tt.arguments, defaults)
+			if !reflect.DeepEqual(tt.arguments, tt.want) {
+				t.Errorf("arguments = %v, want %v", tt.arguments, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolsListHidesDefaultCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		hidden bool
+	}{
+		{"without defaults", newTestConfig(), false},
+		{"with defaults", &Config{Username: "alice", Token: "default-token", Profiles: newProfileStore("")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newTestSession(t, tt.config)
+			session.initialize("2025-06-18", nil)
+			tools := listTools(session, 1)
+
+			schema := tools["get_graph_definition"]["inputSchema"].(map[string]interface{})
+			properties := schema["properties"].(map[string]interface{})
+			if _, shown := properties["token"]; shown == tt.hidden {
+				t.Errorf("get_graph_definition shows token: %v, want %v", shown, !tt.hidden)
+			}
+			// create_user names the user it creates, so it always asks
+			if !requiresProperty(tools["create_user"], "token") {
+				t.Error("create_user does not require token")
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a-know/pixela-mcp/pixela"
+)
+
+// elicitationTimeout bounds how long a destructive call waits for the user
+// to answer the confirmation.
+const elicitationTimeout = 5 * time.Minute
+
+// confirmationSchema is the form shown to the user by elicitation/create.
+var confirmationSchema = &JSONSchema{
+	Type: "object",
+	Properties: map[string]*JSONSchema{
+		"confirm": {Type: "boolean", Description: "Yes, delete it"},
+	},
+	Required: []string{"confirm"},
+}
+
+// confirmDestruction makes sure the user wants a destructive call to go
+// ahead. Clients with elicitation are asked directly with the summary from
+// describe; other clients must pass confirm equal to the target, named by
+// targetField. It returns nil when the call may proceed, and otherwise the
+// result to return instead.
+//
+// The lookups of describe are best effort: they are made once, and left out
+// of the call's retry trace so the result only reports on the call itself.
+func (s *MCPServer) confirmDestruction(ctx context.Context, targetField, target, confirm string, describe func(ctx context.Context) string) map[string]interface{} {
+	if s.canElicit() {
+		accepted, err := s.elicitConfirmation(ctx, describe(pixela.WithoutRetries(ctx)))
+		if err != nil {
+			return s.createAPIErrorResult("Could not confirm the deletion", err)
+		}
+		if !accepted {
+			return s.createSuccessResult("Nothing was deleted: the user did not confirm")
+		}
+		return nil
+	}
+
+	if confirm == target {
+		return nil
+	}
+	return s.toolErrorResult(
+		fmt.Sprintf("Confirmation required: this deletion cannot be undone. To proceed, call the tool again with confirm set to the value of %s.", targetField),
+		ToolError{Code: "confirmation_required"},
+	)
+}
+
+// elicitConfirmation sends elicitation/create and reports whether the user
+// accepted and ticked the confirmation.
+func (s *MCPServer) elicitConfirmation(ctx context.Context, message string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, elicitationTimeout)
+	defer cancel()
+
+	raw, err := s.request(ctx, "elicitation/create", map[string]interface{}{
+		"message":         message,
+		"requestedSchema": confirmationSchema,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var result struct {
+		Action  string                 `json:"action"`
+		Content map[string]interface{} `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return false, fmt.Errorf("invalid elicitation result: %w", err)
+	}
+	confirmed, _ := result.Content["confirm"].(bool)
+	return result.Action == "accept" && confirmed, nil
+}
+
+// The describe functions summarize what a deletion destroys. Lookups that
+// fail only make the summary less specific.
+
+func describeUserDeletion(ctx context.Context, client *pixela.Client, args Credentials) string {
+	resp, err := client.GetGraphsContext(ctx, args.Username, args.Token)
+	if err != nil {
+		return fmt.Sprintf("Delete Pixela user '%s' with all of its graphs and pixels? This cannot be undone.", args.Username)
+	}
+
+	names := make([]string, 0, len(resp.Graphs))
+	for _, graph := range resp.Graphs {
+		names = append(names, fmt.Sprintf("%s (%s)", graph.ID, graph.Name))
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("Delete Pixela user '%s'? The user has no graphs. This cannot be undone.", args.Username)
+	}
+	return fmt.Sprintf("Delete Pixela user '%s' with its %d graph(s) %s and all of their pixels? This cannot be undone.",
+		args.Username, len(names), strings.Join(names, ", "))
+}
+
+func describeGraphDeletion(ctx context.Context, client *pixela.Client, args GraphArgs) string {
+	graph := fmt.Sprintf("'%s'", args.GraphID)
+	if def, err := client.GetGraphDefinitionContext(ctx, args.Username, args.Token, args.GraphID); err == nil {
+		graph = fmt.Sprintf("'%s' (%s)", args.GraphID, def.Name)
+	}
+
+	stats, err := client.GetGraphStatsContext(ctx, args.Username, args.Token, args.GraphID)
+	if err != nil {
+		return fmt.Sprintf("Delete graph %s of user '%s' with all of its pixels? This cannot be undone.", graph, args.Username)
+	}
+	return fmt.Sprintf("Delete graph %s of user '%s' with its %d pixel(s)? This cannot be undone.", graph, args.Username, stats.TotalPixelsCount)
+}
+
+func describePixelDeletion(ctx context.Context, client *pixela.Client, args PixelArgs) string {
+	pixel, err := client.GetPixelContext(ctx, args.Username, args.Token, args.GraphID, args.Date)
+	if err != nil {
+		return fmt.Sprintf("Delete the pixel of %s on graph '%s'?", args.Date, args.GraphID)
+	}
+	return fmt.Sprintf("Delete the pixel of %s (quantity %s) on graph '%s'?", args.Date, pixel.Quantity, args.GraphID)
+}
+
+func describeWebhookDeletion(ctx context.Context, client *pixela.Client, args DeleteWebhookArgs) string {
+	// The hash itself is a secret and is left out of the summary
+	if resp, err := client.GetWebhooksContext(ctx, args.Username, args.Token); err == nil {
+		for _, webhook := range resp.Webhooks {
+			if webhook.WebhookHash == args.WebhookHash {
+				return fmt.Sprintf("Delete the %s webhook of graph '%s' of user '%s'? Anything invoking it will stop working.",
+					webhook.Type, webhook.GraphID, args.Username)
+			}
+		}
+	}
+	return fmt.Sprintf("Delete a webhook of user '%s'? Anything invoking it will stop working.", args.Username)
+}
+
+func describeChannelDeletion(ctx context.Context, client *pixela.Client, args ChannelArgs) string {
+	if resp, err := client.GetChannelsContext(ctx, args.Username, args.Token); err == nil {
+		for _, channel := range resp.Channels {
+			if channel.ID == args.ChannelID {
+				return fmt.Sprintf("Delete channel '%s' (%s, posting to %s) of user '%s'? Notifications sent through it will stop.",
+					channel.ID, channel.Name, channel.Detail.ChannelName, args.Username)
+			}
+		}
+	}
+	return fmt.Sprintf("Delete channel '%s' of user '%s'? Notifications sent through it will stop.", args.ChannelID, args.Username)
+}
+
+func describeNotificationDeletion(ctx context.Context, client *pixela.Client, args NotificationArgs) string {
+	if resp, err := client.GetNotificationsContext(ctx, args.Username, args.Token, args.GraphID); err == nil {
+		for _, notification := range resp.Notifications {
+			if notification.ID == args.NotificationID {
+				return fmt.Sprintf("Delete notification '%s' (%s: %s %s %s, to channel '%s') of graph '%s'?",
+					notification.ID, notification.Name, notification.Target, notification.Condition, notification.Threshold, notification.ChannelID, args.GraphID)
+			}
+		}
+	}
+	return fmt.Sprintf("Delete notification '%s' of graph '%s'?", args.NotificationID, args.GraphID)
+}
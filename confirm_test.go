@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// graphPixela fakes the Pixela endpoints around graph g1 of alice and counts
+// the deletions.
+func graphPixela(t *testing.T) (baseURL string, deletions *atomic.Int32) {
+	t.Helper()
+	deletions = new(atomic.Int32)
+	baseURL = newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/users/alice/graphs/g1/graph-def":
+			w.Write([]byte(`{"id":"g1","name":"Steps","unit":"steps","type":"int","color":"shibafu"}`))
+		case "GET /v1/users/alice/graphs/g1/stats":
+			w.Write([]byte(`{"totalPixelsCount":12}`))
+		case "DELETE /v1/users/alice/graphs/g1":
+			deletions.Add(1)
+			w.Write([]byte(`{"message":"Success.","isSuccess":true}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	return baseURL, deletions
+}
+
+var elicitationCapability = map[string]interface{}{"elicitation": map[string]interface{}{}}
+
+func TestConfirmWithElicitation(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  map[string]interface{}
+		deleted bool
+	}{
+		{"accept", map[string]interface{}{"action": "accept", "content": map[string]interface{}{"confirm": true}}, true},
+		{"accept unticked", map[string]interface{}{"action": "accept", "content": map[string]interface{}{"confirm": false}}, false},
+		{"decline", map[string]interface{}{"action": "decline"}, false},
+		{"cancel", map[string]interface{}{"action": "cancel"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, deletions := graphPixela(t)
+			session := newTestSession(t, newProfileConfig(t, baseURL))
+			session.initialize("2025-06-18", elicitationCapability)
+
+			session.request(1, "tools/call", map[string]interface{}{
+				"name":      "delete_graph",
+				"arguments": map[string]interface{}{"profile": "test", "graphID": "g1"},
+			})
+			elicitation := session.receive()
+			if elicitation["method"] != "elicitation/create" {
+				t.Fatalf("got %v, want an elicitation/create request", elicitation)
+			}
+			params, _ := elicitation["params"].(map[string]interface{})
+			message, _ := params["message"].(string)
+			if !strings.Contains(message, "'g1' (Steps)") || !strings.Contains(message, "12 pixel(s)") {
+				t.Errorf("elicitation message = %q, want the graph name and pixel count", message)
+			}
+
+			session.send(map[string]interface{}{"jsonrpc": "2.0", "id": elicitation["id"], "result": tt.answer})
+			response := session.receive()
+			result, _ := response["result"].(map[string]interface{})
+			if result == nil || result["isError"] == true {
+				t.Fatalf("delete_graph = %v, want a successful result", response)
+			}
+			if got := deletions.Load() == 1; got != tt.deleted {
+				t.Errorf("deleted: %v, want %v", got, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestConfirmArgument(t *testing.T) {
+	tests := []struct {
+		name            string
+		protocolVersion string
+		capabilities    map[string]interface{}
+	}{
+		{"client without elicitation", "2025-06-18", nil},
+		{"client on 2024-11-05", "2024-11-05", elicitationCapability},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, deletions := graphPixela(t)
+			session := newTestSession(t, newProfileConfig(t, baseURL))
+			session.initialize(tt.protocolVersion, tt.capabilities)
+
+			for i, confirm := range []interface{}{nil, "g2"} {
+				arguments := map[string]interface{}{"profile": "test", "graphID": "g1"}
+				if confirm != nil {
+					arguments["confirm"] = confirm
+				}
+				result := session.call(i+1, "delete_graph", arguments)
+				meta, _ := result["_meta"].(map[string]interface{})
+				toolErr, _ := meta["pixela-mcp/error"].(map[string]interface{})
+				if result["isError"] != true || toolErr["code"] != "confirmation_required" {
+					t.Errorf("delete_graph with confirm %v = %v, want confirmation_required", confirm, result)
+				}
+			}
+			if deletions.Load() != 0 {
+				t.Fatal("deleted without confirmation")
+			}
+
+			result := session.call(3, "delete_graph", map[string]interface{}{"profile": "test", "graphID": "g1", "confirm": "g1"})
+			if result["isError"] == true {
+				t.Errorf("delete_graph with confirm = %v, want success", result)
+			}
+			if deletions.Load() != 1 {
+				t.Error("not deleted after confirmation")
+			}
+			// No elicitation/create was sent at any point
+			session.expectSilence(50 * time.Millisecond)
+		})
+	}
+}
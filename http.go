@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionIDHeader       = "Mcp-Session-Id"
+	protocolVersionHeader = "MCP-Protocol-Version"
+
+	// maxMessageSize bounds the body of a POSTed JSON-RPC message.
+	maxMessageSize = 4 << 20
+	// sessionIdleTimeout is how long a session without any traffic (or an
+	// open SSE stream) is kept before it is dropped.
+	sessionIdleTimeout = 30 * time.Minute
+	// sessionSweepInterval is how often idle sessions are looked for.
+	sessionSweepInterval = time.Minute
+	// sseKeepAliveInterval is how often a comment is written to idle SSE
+	// streams so proxies do not close them.
+	sseKeepAliveInterval = 15 * time.Second
+	// sseQueueSize is how many messages are buffered per session while its
+	// SSE stream is not open or not keeping up.
+	sseQueueSize = 64
+)
+
+// serveHTTP serves the HTTP transports on addr until ctx is cancelled. When
+// authToken is set every request must carry it as a bearer token; without
+// one only loopback addresses are served, since any caller can run every
+// tool with the configured credentials.
+func serveHTTP(ctx context.Context, addr, authToken string, tools *ToolRegistry, config *Config) error {
+	if authToken == "" && !loopbackAddr(addr) {
+		return fmt.Errorf("refusing to serve on %s without authentication: set PIXELA_MCP_AUTH_TOKEN or listen on a loopback address", addr)
+	}
+
+	streamable := NewStreamableHTTPHandler(tools, config)
+	legacy := NewLegacySSEHandler(tools, config)
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", streamable)
+	mux.HandleFunc("/sse", legacy.ServeStream)
+	mux.HandleFunc("/messages", legacy.ServeMessage)
+
+	var handler http.Handler = mux
+	if authToken != "" {
+		handler = requireBearer(authToken, mux)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Cancel in-flight calls and end SSE streams so Shutdown can complete
+	srv.RegisterOnShutdown(streamable.closeSessions)
+	srv.RegisterOnShutdown(legacy.closeSessions)
+	go streamable.sweepSessions(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	log.Printf("Serving MCP Streamable HTTP on %s/mcp and HTTP+SSE on %s/sse", addr, addr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// StreamableHTTPHandler serves the MCP Streamable HTTP transport on a single
+// endpoint. Clients POST one JSON-RPC message per request and get the
+// response back as JSON; a GET opens an SSE stream carrying server-initiated
+// messages; DELETE ends the session. Every initialize request starts a new
+// session, identified by the Mcp-Session-Id header.
+type StreamableHTTPHandler struct {
+	tools  *ToolRegistry
+	config *Config
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+func NewStreamableHTTPHandler(tools *ToolRegistry, config *Config) *StreamableHTTPHandler {
+	return &StreamableHTTPHandler{
+		tools:    tools,
+		config:   config,
+		sessions: make(map[string]*httpSession),
+	}
+}
+
+// httpSession is the Transport of one session's MCPServer: messages the
+// server sends on its own are queued for the session's SSE stream.
+type httpSession struct {
+	id     string
+	server *MCPServer
+	ctx    context.Context
+	cancel context.CancelFunc
+	events chan []byte
+
+	mu        sync.Mutex
+	streaming bool
+	lastUsed  time.Time
+}
+
+func (s *httpSession) Send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	select {
+	case s.events <- data:
+		return nil
+	default:
+		return errors.New("SSE queue is full, message dropped")
+	}
+}
+
+func (s *httpSession) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+}
+
+func (s *httpSession) idleSince(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streaming {
+		return 0
+	}
+	return now.Sub(s.lastUsed)
+}
+
+func (h *StreamableHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleGet(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StreamableHTTPHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var req MCPRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Printf("Error parsing JSON: %v", err)
+		writeJSON(w, http.StatusBadRequest, MCPResponse{
+			JSONRPC: "2.0",
+			Error:   &MCPError{Code: errCodeParseError, Message: "Parse error"},
+		})
+		return
+	}
+
+	if req.Method == "initialize" && !req.IsNotification() {
+		session, err := h.newSession()
+		if err != nil {
+			log.Printf("Error creating session: %v", err)
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		response := session.server.handleRequest(r.Context(), req)
+		if response == nil {
+			// The client went away; nobody will ever use the session
+			h.removeSession(session)
+			http.Error(w, "Request cancelled", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(sessionIDHeader, session.id)
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	session, ok := h.lookupSession(w, r)
+	if !ok {
+		return
+	}
+
+	switch {
+	case req.Method == "":
+		session.server.handleResponse(body)
+		w.WriteHeader(http.StatusAccepted)
+	case req.IsNotification():
+		session.server.handleNotification(req)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		// The call ends when either the client goes away or the session is
+		// deleted
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(session.ctx, cancel)
+		defer stop()
+
+		// Requests the server sends while answering (such as elicitation)
+		// go out on this response, which then becomes an SSE stream
+		stream := &postStream{w: w, session: session, sse: strings.Contains(r.Header.Get("Accept"), "text/event-stream")}
+		reqCtx, done := session.server.trackRequest(withTransport(ctx, stream), req.ID)
+		defer done()
+
+		if !session.server.acquireWorker(reqCtx, req) {
+			stream.finish(nil)
+			return
+		}
+		stream.finish(session.server.serveRequest(reqCtx, req))
+	}
+}
+
+// postStream is the Transport of a single POSTed request. The response is
+// plain JSON unless the server sends messages related to the request
+// before answering; if the client accepts SSE those are streamed on the
+// response, otherwise they go to the session's GET stream.
+type postStream struct {
+	w       http.ResponseWriter
+	session *httpSession
+	sse     bool
+
+	mu     sync.Mutex
+	stream *sseStream
+}
+
+func (p *postStream) Send(message interface{}) error {
+	if !p.sse {
+		return p.session.Send(message)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream == nil {
+		stream, ok := newSSEStream(p.w)
+		if !ok {
+			p.sse = false
+			return p.session.Send(message)
+		}
+		p.stream = stream
+	}
+	return p.stream.event("message", data)
+}
+
+// finish writes the response, or ends the exchange without one when the
+// request was cancelled.
+func (p *postStream) finish(response *MCPResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stream != nil {
+		if response != nil {
+			data, err := json.Marshal(response)
+			if err != nil {
+				log.Printf("Error marshaling response: %v", err)
+				return
+			}
+			if err := p.stream.event("message", data); err != nil {
+				log.Printf("Error writing response: %v", err)
+			}
+		}
+		return
+	}
+
+	if response == nil {
+		// Cancelled; the client no longer expects a response
+		p.w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(p.w, http.StatusOK, response)
+}
+
+// handleGet streams the session's server-initiated messages that are not
+// related to a POSTed request as SSE events.
+func (h *StreamableHTTPHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, ok := h.lookupSession(w, r)
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	if session.streaming {
+		session.mu.Unlock()
+		http.Error(w, "An SSE stream is already open for this session", http.StatusConflict)
+		return
+	}
+	session.streaming = true
+	session.mu.Unlock()
+	defer func() {
+		session.mu.Lock()
+		session.streaming = false
+		session.lastUsed = time.Now()
+		session.mu.Unlock()
+	}()
+
+	stream, ok := newSSEStream(w)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	stream.serve(r.Context(), session.ctx, session.events)
+}
+
+func (h *StreamableHTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookupSession(w, r)
+	if !ok {
+		return
+	}
+
+	h.removeSession(session)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeSession ends a session and cancels its in-flight calls.
+func (h *StreamableHTTPHandler) removeSession(session *httpSession) {
+	h.mu.Lock()
+	delete(h.sessions, session.id)
+	h.mu.Unlock()
+	session.cancel()
+}
+
+func (h *StreamableHTTPHandler) newSession() (*httpSession, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &httpSession{
+		id:       id,
+		ctx:      ctx,
+		cancel:   cancel,
+		events:   make(chan []byte, sseQueueSize),
+		lastUsed: time.Now(),
+	}
+	session.server = NewMCPServer(h.tools, h.config, session)
+	session.server.watchProfiles(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessions[id] = session
+	return session, nil
+}
+
+// lookupSession returns the session named by the request headers, or writes
+// the error response and returns false.
+func (h *StreamableHTTPHandler) lookupSession(w http.ResponseWriter, r *http.Request) (*httpSession, bool) {
+	id := r.Header.Get(sessionIDHeader)
+	if id == "" {
+		http.Error(w, "Missing "+sessionIDHeader+" header", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if v := r.Header.Get(protocolVersionHeader); v != "" && !containsString(supportedProtocolVersions, v) {
+		http.Error(w, "Unsupported "+protocolVersionHeader+": "+v, http.StatusBadRequest)
+		return nil, false
+	}
+
+	h.mu.Lock()
+	session, ok := h.sessions[id]
+	h.mu.Unlock()
+	if !ok {
+		// Tells the client to start over with initialize
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	session.touch()
+	return session, true
+}
+
+// sweepSessions drops idle sessions every sessionSweepInterval until ctx is
+// cancelled.
+func (h *StreamableHTTPHandler) sweepSessions(ctx context.Context) {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.expireSessions(now)
+		}
+	}
+}
+
+// expireSessions drops the sessions that have been idle for longer than
+// sessionIdleTimeout at now.
+func (h *StreamableHTTPHandler) expireSessions(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, session := range h.sessions {
+		if session.idleSince(now) > sessionIdleTimeout {
+			log.Printf("Session %s expired", id)
+			delete(h.sessions, id)
+			session.cancel()
+		}
+	}
+}
+
+func (h *StreamableHTTPHandler) closeSessions() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, session := range h.sessions {
+		delete(h.sessions, id)
+		session.cancel()
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// allowedOrigin guards against DNS rebinding: browsers may only call the
+// server from the same host or from localhost.
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if u.Host == r.Host {
+		return true
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loopbackAddr reports whether a listen address only accepts local
+// connections. An empty host listens on every interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireBearer rejects requests whose Authorization header does not carry
+// token as a bearer token.
+func requireBearer(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pixela-mcp"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// sseStream writes Server-Sent Events to a response.
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSEStream(w http.ResponseWriter) (*sseStream, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseStream{w: w, flusher: flusher}, true
+}
+
+func (s *sseStream) event(name string, data []byte) error {
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseStream) keepAlive() error {
+	if _, err := io.WriteString(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// serve writes every message from events as a "message" event until the
+// client disconnects or done is cancelled, with keep-alive comments while
+// idle.
+func (s *sseStream) serve(clientCtx, done context.Context, events <-chan []byte) {
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-clientCtx.Done():
+			return
+		case <-done.Done():
+			return
+		case data := <-events:
+			if err := s.event("message", data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := s.keepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const initializeBody = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`
+
+// postMCP POSTs a message to the Streamable HTTP endpoint, with the session
+// id unless it is empty.
+func postMCP(t *testing.T, url, sessionID, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(sessionIDHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decodeResponse reads a JSON-RPC response body.
+func decodeResponse(t *testing.T, resp *http.Response) map[string]interface{} {
+	t.Helper()
+	var message map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+	return message
+}
+
+func newStreamableServer(t *testing.T) (*StreamableHTTPHandler, string) {
+	t.Helper()
+	handler := NewStreamableHTTPHandler(newToolRegistry(), newTestConfig())
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Cleanup(handler.closeSessions)
+	return handler, server.URL
+}
+
+func TestStreamableHTTPSession(t *testing.T) {
+	_, url := newStreamableServer(t)
+
+	resp := postMCP(t, url, "", initializeBody)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d, want 200", resp.StatusCode)
+	}
+	sessionID := resp.Header.Get(sessionIDHeader)
+	if sessionID == "" {
+		t.Fatalf("initialize response has no %s header", sessionIDHeader)
+	}
+	if message := decodeResponse(t, resp); message["result"] == nil {
+		t.Errorf("initialize response = %v, want a result", message)
+	}
+
+	resp = postMCP(t, url, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification status = %d, want 202", resp.StatusCode)
+	}
+
+	resp = postMCP(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("tools/list status = %d, want 200", resp.StatusCode)
+	}
+	message := decodeResponse(t, resp)
+	result, _ := message["result"].(map[string]interface{})
+	if tools, _ := result["tools"].([]interface{}); len(tools) == 0 {
+		t.Errorf("tools/list response = %v, want the tools", message)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	req.Header.Set(sessionIDHeader, sessionID)
+	deleted, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted.Body.Close()
+	if deleted.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", deleted.StatusCode)
+	}
+
+	resp = postMCP(t, url, sessionID, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status after DELETE = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestStreamableHTTPRejects(t *testing.T) {
+	_, url := newStreamableServer(t)
+	sessionID := postMCP(t, url, "", initializeBody).Header.Get(sessionIDHeader)
+
+	tests := []struct {
+		name      string
+		sessionID string
+		body      string
+		want      int
+	}{
+		{"missing session id", "", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, http.StatusBadRequest},
+		{"unknown session id", "0123456789abcdef", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, http.StatusNotFound},
+		{"malformed JSON", sessionID, `{"jsonrpc":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := postMCP(t, url, tt.sessionID, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamableHTTPCancelledInitialize(t *testing.T) {
+	handler := NewStreamableHTTPHandler(newToolRegistry(), newTestConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(initializeBody)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusOK {
+		t.Errorf("status = 200 with body %q, want an error status", rec.Body)
+	}
+	if id := rec.Header().Get(sessionIDHeader); id != "" {
+		t.Errorf("response carries session id %s, want none", id)
+	}
+	if len(handler.sessions) != 0 {
+		t.Errorf("%d session(s) kept, want none", len(handler.sessions))
+	}
+}
+
+func TestExpireSessions(t *testing.T) {
+	handler, url := newStreamableServer(t)
+	idle := postMCP(t, url, "", initializeBody).Header.Get(sessionIDHeader)
+	active := postMCP(t, url, "", initializeBody).Header.Get(sessionIDHeader)
+
+	handler.mu.Lock()
+	idleSession := handler.sessions[idle]
+	handler.mu.Unlock()
+	idleSession.mu.Lock()
+	idleSession.lastUsed = time.Now().Add(-sessionIdleTimeout - time.Minute)
+	idleSession.mu.Unlock()
+
+	handler.expireSessions(time.Now())
+
+	if resp := postMCP(t, url, idle, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("idle session status = %d, want 404", resp.StatusCode)
+	}
+	if idleSession.ctx.Err() == nil {
+		t.Error("idle session was not cancelled")
+	}
+	if resp := postMCP(t, url, active, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("active session status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestRequireBearer(t *testing.T) {
+	handler := requireBearer("s3cret-auth-token", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer wrong-token", http.StatusUnauthorized},
+		{"not bearer", "Basic czNjcmV0LWF1dGgtdG9rZW4=", http.StatusUnauthorized},
+		{"right token", "Bearer s3cret-auth-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestServeHTTPRequiresTokenOffLoopback(t *testing.T) {
+	err := serveHTTP(context.Background(), "0.0.0.0:0", "", newToolRegistry(), newTestConfig())
+	if err == nil || !strings.Contains(err.Error(), "PIXELA_MCP_AUTH_TOKEN") {
+		t.Errorf("serveHTTP on 0.0.0.0 without a token = %v, want a refusal", err)
+	}
+}
+
+func TestLoopbackAddr(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"192.0.2.1:8080": false,
+		"example.com:80": false,
+		"127.0.0.1":      false,
+	}
+	for addr, want := range tests {
+		if got := loopbackAddr(addr); got != want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
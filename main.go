@@ -2,19 +2,42 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/joho/godotenv"
 )
 
+// JSON-RPC and MCP error codes
+const (
+	errCodeParseError     = -32700
+	errCodeInvalidRequest = -32600
+	errCodeMethodNotFound = -32601
+	errCodeInvalidParams  = -32602
+	errCodeNotInitialized = -32002
+)
+
 type MCPRequest struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id"`
-	Method  string      `json:"method"`
-	Params  interface{} `json:"params,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  interface{}     `json:"params,omitempty"`
+}
+
+// IsNotification reports whether the message carries no id. Notifications
+// must never be answered.
+func (r MCPRequest) IsNotification() bool {
+	return len(r.ID) == 0
 }
 
 type MCPResponse struct {
@@ -30,21 +53,72 @@ type MCPError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// lifecycleState tracks the MCP initialization handshake.
+type lifecycleState int
+
+const (
+	// stateUninitialized: no initialize request received yet
+	stateUninitialized lifecycleState = iota
+	// stateInitializing: initialize answered, waiting for notifications/initialized
+	stateInitializing
+	// stateReady: handshake completed
+	stateReady
+)
+
+// maxConcurrentRequests bounds the number of requests processed at once.
+const maxConcurrentRequests = 8
+
+// MCPServer holds the state of one client session. Messages reach it from a
+// transport (stdio, or one Streamable HTTP session) and responses and other
+// server-to-client messages leave through the same transport.
 type MCPServer struct {
-	scanner *bufio.Scanner
-	writer  *bufio.Writer
+	tools     *ToolRegistry
+	config    *Config
+	transport Transport
+	workers   chan struct{}
+
+	mu                 sync.Mutex
+	state              lifecycleState
+	protocolVersion    string
+	features           protocolFeatures
+	clientCapabilities ClientCapabilities
+	inFlight           map[string]context.CancelFunc
+
+	// pending holds the server-initiated requests awaiting a response from
+	// the client, by id. Once the client is gone, disconnected is set and
+	// no further requests are sent.
+	pending       map[string]chan *clientResponse
+	nextRequestID int
+	disconnected  bool
 }
 
-func NewMCPServer() *MCPServer {
+func NewMCPServer(tools *ToolRegistry, config *Config, transport Transport) *MCPServer {
 	return &MCPServer{
-		scanner: bufio.NewScanner(os.Stdin),
-		writer:  bufio.NewWriter(os.Stdout),
+		tools:     tools,
+		config:    config,
+		transport: transport,
+		workers:   make(chan struct{}, maxConcurrentRequests),
+		inFlight:  make(map[string]context.CancelFunc),
+		pending:   make(map[string]chan *clientResponse),
 	}
 }
 
-func (s *MCPServer) run() {
-	for s.scanner.Scan() {
-		line := strings.TrimSpace(s.scanner.Text())
+// run reads newline-delimited requests from in until it is closed or ctx is
+// cancelled. The initialize handshake and notifications are handled inline;
+// every other request runs in its own goroutine, at most
+// maxConcurrentRequests at a time. While all of them are busy, reading
+// pauses until one finishes; calls waiting for an elicitation answer give up
+// after elicitationTimeout, so this cannot stall for good.
+func (s *MCPServer) run(ctx context.Context, in io.Reader) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.watchProfiles(ctx)
+
+	var wg sync.WaitGroup
+	scanner := bufio.NewScanner(in)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
@@ -52,795 +126,412 @@ func (s *MCPServer) run() {
 		var req MCPRequest
 		if err := json.Unmarshal([]byte(line), &req); err != nil {
 			log.Printf("Error parsing JSON: %v", err)
+			s.sendResponse(MCPResponse{
+				JSONRPC: "2.0",
+				Error:   &MCPError{Code: errCodeParseError, Message: "Parse error"},
+			})
+			continue
+		}
+
+		if req.Method == "" {
+			s.handleResponse([]byte(line))
+			continue
+		}
+
+		if req.IsNotification() {
+			s.handleNotification(req)
+			continue
+		}
+
+		if req.Method == "initialize" {
+			// Answer before reading on so that following requests see the
+			// initialized state
+			s.sendResponse(*s.handleRequest(ctx, req))
 			continue
 		}
 
-		response := s.handleRequest(req)
-		s.sendResponse(response)
+		// Check the state in read order: a request sent before initialize
+		// must be rejected even if initialize is answered before it runs
+		if err := s.checkLifecycle(req.Method); err != nil {
+			s.sendResponse(MCPResponse{JSONRPC: "2.0", ID: req.ID, Error: err})
+			continue
+		}
+
+		reqCtx, done := s.trackRequest(ctx, req.ID)
+		if !s.acquireWorker(reqCtx, req) {
+			done()
+			continue
+		}
+		wg.Add(1)
+		go func(req MCPRequest) {
+			defer wg.Done()
+			defer done()
+
+			if response := s.serveRequest(reqCtx, req); response != nil {
+				s.sendResponse(*response)
+			}
+		}(req)
 	}
 
-	if err := s.scanner.Err(); err != nil {
+	if err := scanner.Err(); err != nil {
 		log.Printf("Error reading stdin: %v", err)
 	}
+
+	// No responses can arrive anymore; fail requests still waiting for one
+	s.failPending()
+
+	// Let in-flight requests finish; a shutdown signal cancels them via ctx
+	wg.Wait()
+}
+
+// acquireWorker waits for one of the maxConcurrentRequests worker slots,
+// which serveRequest releases. It reports false when ctx is cancelled first.
+func (s *MCPServer) acquireWorker(ctx context.Context, req MCPRequest) bool {
+	select {
+	case s.workers <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		log.Printf("Request %s was cancelled before it started", string(req.ID))
+		return false
+	}
 }
 
-func (s *MCPServer) handleRequest(req MCPRequest) MCPResponse {
-	var response MCPResponse
-	response.JSONRPC = "2.0"
-	response.ID = req.ID
+// serveRequest answers req in the worker slot taken by acquireWorker and
+// releases it. ctx must come from trackRequest. It returns nil when the
+// request was cancelled.
+func (s *MCPServer) serveRequest(ctx context.Context, req MCPRequest) *MCPResponse {
+	defer func() { <-s.workers }()
+	return s.handleRequest(ctx, req)
+}
+
+// handleRequest answers a request. It returns nil when ctx was cancelled
+// while the request was processed, in which case no response must be sent.
+func (s *MCPServer) handleRequest(ctx context.Context, req MCPRequest) *MCPResponse {
+	response := &MCPResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+	}
+
+	if req.JSONRPC != "2.0" {
+		response.Error = &MCPError{Code: errCodeInvalidRequest, Message: "Invalid Request: jsonrpc must be \"2.0\""}
+		return response
+	}
 
 	switch req.Method {
 	case "initialize":
 		response.Result = s.handleInitialize(req.Params)
+		s.setState(stateInitializing)
+	case "ping":
+		response.Result = map[string]interface{}{}
 	case "tools/list":
+		if err := s.checkLifecycle(req.Method); err != nil {
+			response.Error = err
+			break
+		}
 		response.Result = s.handleToolsList()
 	case "tools/call":
-		response.Result = s.handleToolsCall(req.Params)
+		if err := s.checkLifecycle(req.Method); err != nil {
+			response.Error = err
+			break
+		}
+
+		result, err := s.handleToolsCall(ctx, req.Params)
+		if err != nil {
+			response.Error = err
+		} else {
+			response.Result = result
+		}
 	default:
 		response.Error = &MCPError{
-			Code:    -32601,
+			Code:    errCodeMethodNotFound,
 			Message: "Method not found",
 		}
 	}
 
+	if ctx.Err() != nil {
+		log.Printf("Request %s was cancelled, dropping response", string(req.ID))
+		return nil
+	}
+
 	return response
 }
 
-func (s *MCPServer) sendResponse(response MCPResponse) {
-	responseBytes, err := json.Marshal(response)
+func (s *MCPServer) handleNotification(req MCPRequest) {
+	switch req.Method {
+	case "notifications/initialized":
+		s.setState(stateReady)
+	case "notifications/cancelled":
+		s.handleCancelled(req.Params)
+	default:
+		log.Printf("Ignoring notification: %s", req.Method)
+	}
+}
+
+func (s *MCPServer) setState(state lifecycleState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// checkLifecycle rejects the tools methods until the initialize request has
+// been answered; initialize and ping are always allowed.
+func (s *MCPServer) checkLifecycle(method string) *MCPError {
+	if method != "tools/list" && method != "tools/call" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == stateUninitialized {
+		return &MCPError{Code: errCodeNotInitialized, Message: "Server not initialized"}
+	}
+	return nil
+}
+
+// trackRequest registers an in-flight request so notifications/cancelled can
+// reach it. The returned func must be called once the request has finished.
+func (s *MCPServer) trackRequest(parent context.Context, id json.RawMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	key := string(id)
+
+	s.mu.Lock()
+	s.inFlight[key] = cancel
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.inFlight, key)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+func (s *MCPServer) handleCancelled(params interface{}) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return
+	}
+	id, err := json.Marshal(paramsMap["requestId"])
 	if err != nil {
-		log.Printf("Error marshaling response: %v", err)
 		return
 	}
 
-	_, err = s.writer.WriteString(string(responseBytes) + "\n")
+	s.mu.Lock()
+	cancel, ok := s.inFlight[string(id)]
+	s.mu.Unlock()
+	if !ok {
+		// Already finished or unknown; nothing to do
+		return
+	}
+
+	if reason, _ := paramsMap["reason"].(string); reason != "" {
+		log.Printf("Request %s cancelled: %s", string(id), reason)
+	}
+	cancel()
+}
+
+// clientResponse is the client's answer to a request sent by the server.
+type clientResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *MCPError       `json:"error,omitempty"`
+}
+
+// request sends a request to the client and waits for its response. The
+// request goes out through the transport carried by ctx, if any, so that
+// transports can relate it to the call being processed.
+func (s *MCPServer) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	s.mu.Lock()
+	if s.disconnected {
+		s.mu.Unlock()
+		return nil, errors.New("client disconnected")
+	}
+	s.nextRequestID++
+	id, _ := json.Marshal(fmt.Sprintf("pixela-mcp-%d", s.nextRequestID))
+	ch := make(chan *clientResponse, 1)
+	s.pending[string(id)] = ch
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, string(id))
+		s.mu.Unlock()
+	}()
+
+	err := transportFrom(ctx, s.transport).Send(MCPRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	})
 	if err != nil {
-		log.Printf("Error writing response: %v", err)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return nil, errors.New("client disconnected")
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s failed: %s (code %d)", method, resp.Error.Message, resp.Error.Code)
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleResponse routes a response from the client to the request waiting
+// for it.
+func (s *MCPServer) handleResponse(data []byte) {
+	var resp clientResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		log.Printf("Error parsing response: %v", err)
 		return
 	}
 
-	if err := s.writer.Flush(); err != nil {
-		log.Printf("Error flushing response: %v", err)
+	s.mu.Lock()
+	ch, ok := s.pending[string(resp.ID)]
+	delete(s.pending, string(resp.ID))
+	s.mu.Unlock()
+	if !ok {
+		log.Printf("Ignoring response to unknown request (id: %s)", string(resp.ID))
+		return
 	}
+	ch <- &resp
 }
 
-func (s *MCPServer) handleInitialize(params interface{}) map[string]interface{} {
-	return map[string]interface{}{
-		"protocolVersion": "2024-11-05",
-		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{
-				"listChanged": true,
-			},
-		},
-		"serverInfo": map[string]interface{}{
-			"name":    "pixela-mcp",
-			"version": "1.0.0",
-		},
+func (s *MCPServer) failPending() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnected = true
+	for id, ch := range s.pending {
+		delete(s.pending, id)
+		close(ch)
+	}
+}
+
+// watchProfiles sends notifications/tools/list_changed whenever the profiles
+// are reloaded until ctx is cancelled, since whether tools take a profile
+// argument depends on them.
+func (s *MCPServer) watchProfiles(ctx context.Context) {
+	s.config.Profiles.Subscribe(ctx, func() {
+		s.mu.Lock()
+		ready := s.state == stateReady && !s.disconnected
+		s.mu.Unlock()
+		if !ready {
+			return
+		}
+
+		notification := MCPRequest{JSONRPC: "2.0", Method: "notifications/tools/list_changed"}
+		if err := s.transport.Send(notification); err != nil {
+			log.Printf("Error sending tools list change: %v", err)
+		}
+	})
+}
+
+func (s *MCPServer) sendResponse(response MCPResponse) {
+	if err := s.transport.Send(response); err != nil {
+		log.Printf("Error sending response: %v", err)
 	}
 }
 
 func (s *MCPServer) handleToolsList() map[string]interface{} {
+	_, hasDefaults := s.config.DefaultCredentials()
 	return map[string]interface{}{
-		"tools": []map[string]interface{}{
-			{
-				"name":        "create_user",
-				"description": "Create a user on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"agreeTermsOfService": map[string]interface{}{
-							"type":        "string",
-							"description": "Agreement to the terms of service (yes/no)",
-						},
-						"notMinor": map[string]interface{}{
-							"type":        "string",
-							"description": "Confirmation of not being a minor (yes/no)",
-						},
-					},
-					"required": []string{"username", "token", "agreeTermsOfService", "notMinor"},
-				},
-			},
-			{
-				"name":        "create_graph",
-				"description": "Create a graph on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph name",
-						},
-						"unit": map[string]interface{}{
-							"type":        "string",
-							"description": "Unit",
-						},
-						"type": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph type (int/float)",
-						},
-						"color": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph color",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "name", "unit", "type", "color"},
-				},
-			},
-			{
-				"name":        "post_pixel",
-				"description": "Post a pixel to Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"date": map[string]interface{}{
-							"type":        "string",
-							"description": "Date (yyyyMMdd format)",
-						},
-						"quantity": map[string]interface{}{
-							"type":        "string",
-							"description": "Quantity",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "date", "quantity"},
-				},
-			},
-			{
-				"name":        "delete_user",
-				"description": "Delete a user on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-					},
-					"required": []string{"username", "token"},
-				},
-			},
-			{
-				"name":        "update_user",
-				"description": "Update user information on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"newToken": map[string]interface{}{
-							"type":        "string",
-							"description": "New authentication token",
-						},
-					},
-					"required": []string{"username", "token", "newToken"},
-				},
-			},
-			{
-				"name":        "update_user_profile",
-				"description": "Update user profile on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"displayName": map[string]interface{}{
-							"type":        "string",
-							"description": "Display name",
-						},
-						"gravatarIconEmail": map[string]interface{}{
-							"type":        "string",
-							"description": "Gravatar icon email address",
-						},
-						"title": map[string]interface{}{
-							"type":        "string",
-							"description": "Title",
-						},
-						"about": map[string]interface{}{
-							"type":        "string",
-							"description": "About",
-						},
-						"pixelaGraph": map[string]interface{}{
-							"type":        "string",
-							"description": "Pixela graph URL",
-						},
-						"timezone": map[string]interface{}{
-							"type":        "string",
-							"description": "Timezone",
-						},
-						"contributeURLs": map[string]interface{}{
-							"type":        "string",
-							"description": "Contribute URLs (comma-separated)",
-						},
-					},
-					"required": []string{"username", "token"},
-				},
-			},
-			{
-				"name":        "get_graphs",
-				"description": "Get a list of graphs on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-					},
-					"required": []string{"username", "token"},
-				},
-			},
-			{
-				"name":        "get_graph_definition",
-				"description": "Get graph definition on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "update_graph",
-				"description": "Update a graph on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph name",
-						},
-						"unit": map[string]interface{}{
-							"type":        "string",
-							"description": "Unit",
-						},
-						"color": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph color",
-						},
-						"purgeCacheURLs": map[string]interface{}{
-							"type":        "string",
-							"description": "Purge cache URLs (comma-separated)",
-						},
-						"selfSufficient": map[string]interface{}{
-							"type":        "string",
-							"description": "Self-sufficient (increment/decrement/none)",
-						},
-						"isSecret": map[string]interface{}{
-							"type":        "string",
-							"description": "Is secret graph (true/false)",
-						},
-						"publishOptionalData": map[string]interface{}{
-							"type":        "string",
-							"description": "Publish optional data (true/false)",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "delete_graph",
-				"description": "Delete a graph on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "get_pixels",
-				"description": "Get a list of pixels on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"from": map[string]interface{}{
-							"type":        "string",
-							"description": "Start date (yyyyMMdd format)",
-						},
-						"to": map[string]interface{}{
-							"type":        "string",
-							"description": "End date (yyyyMMdd format)",
-						},
-						"mode": map[string]interface{}{
-							"type":        "string",
-							"description": "Mode (short/shortDetail)",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "get_graph_stats",
-				"description": "Get graph statistics on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "batch_post_pixels",
-				"description": "Batch post pixels to Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"pixels": map[string]interface{}{
-							"type":        "string",
-							"description": "Pixel data (JSON format)",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "pixels"},
-				},
-			},
-			{
-				"name":        "get_pixel",
-				"description": "Get a specific pixel on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"date": map[string]interface{}{
-							"type":        "string",
-							"description": "Date (yyyyMMdd format)",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "date"},
-				},
-			},
-			{
-				"name":        "get_latest_pixel",
-				"description": "Get the latest pixel on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "get_today_pixel",
-				"description": "Get today's pixel on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "update_pixel",
-				"description": "Update a pixel on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"date": map[string]interface{}{
-							"type":        "string",
-							"description": "Date (yyyyMMdd format)",
-						},
-						"quantity": map[string]interface{}{
-							"type":        "string",
-							"description": "Quantity",
-						},
-						"optionalData": map[string]interface{}{
-							"type":        "string",
-							"description": "Optional data (optional)",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "date", "quantity"},
-				},
-			},
-			{
-				"name":        "delete_pixel",
-				"description": "Delete a specific pixel on a specific graph on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"date": map[string]interface{}{
-							"type":        "string",
-							"description": "Date (yyyyMMdd format)",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "date"},
-				},
-			},
-			{
-				"name":        "increment_pixel",
-				"description": "Increment the today's pixel on a specific graph on Pixela (for int graphs +1, for float graphs +0.01)",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "decrement_pixel",
-				"description": "Decrement the today's pixel on a specific graph on Pixela (for int graphs -1, for float graphs -0.01)",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-			{
-				"name":        "create_webhook",
-				"description": "Create a new webhook on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"type": map[string]interface{}{
-							"type":        "string",
-							"description": "Webhook type (increment/decrement)",
-						},
-						"quantity": map[string]interface{}{
-							"type":        "string",
-							"description": "Quantity (optional)",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "type"},
-				},
-			},
-			{
-				"name":        "get_webhooks",
-				"description": "Get a list of existing webhooks on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-					},
-					"required": []string{"username", "token"},
-				},
-			},
-			{
-				"name":        "invoke_webhook",
-				"description": "Invoke a specific webhook on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"webhookHash": map[string]interface{}{
-							"type":        "string",
-							"description": "Webhook hash",
-						},
-					},
-					"required": []string{"username", "webhookHash"},
-				},
-			},
-			{
-				"name":        "delete_webhook",
-				"description": "Delete a specific webhook on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"webhookHash": map[string]interface{}{
-							"type":        "string",
-							"description": "Webhook hash",
-						},
-					},
-					"required": []string{"username", "token", "webhookHash"},
-				},
-			},
-			{
-				"name":        "add_pixel",
-				"description": "Add a value to today's pixel on a specific graph on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"quantity": map[string]interface{}{
-							"type":        "string",
-							"description": "Value to add (string, required)",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "quantity"},
-				},
-			},
-			{
-				"name":        "subtract_pixel",
-				"description": "Subtract a value from today's pixel on a specific graph on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-						"quantity": map[string]interface{}{
-							"type":        "string",
-							"description": "Value to subtract (string, required)",
-						},
-					},
-					"required": []string{"username", "token", "graphID", "quantity"},
-				},
-			},
-			{
-				"name":        "stopwatch",
-				"description": "Start or stop the stopwatch for a specific graph on Pixela",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"username": map[string]interface{}{
-							"type":        "string",
-							"description": "User name",
-						},
-						"token": map[string]interface{}{
-							"type":        "string",
-							"description": "Authentication token",
-						},
-						"graphID": map[string]interface{}{
-							"type":        "string",
-							"description": "Graph ID",
-						},
-					},
-					"required": []string{"username", "token", "graphID"},
-				},
-			},
-		},
+		"tools": s.tools.List(listOptions{
+			features:        s.protocolFeatures(),
+			hideCredentials: hasDefaults,
+			profileArgument: s.config.Profiles.Len() > 0,
+			policy:          s.config.Policy,
+			elicitation:     s.canElicit(),
+		}),
 	}
 }
 
 func main() {
+	log.SetOutput(redactingWriter{w: os.Stderr})
+
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
 
-	server := NewMCPServer()
-	server.run()
+	transport := flag.String("transport", "", "transport to serve: stdio or http (default: http when PORT is set, otherwise stdio)")
+	addr := flag.String("addr", "", "listen address of the http transport (default: 127.0.0.1:$PORT, or 127.0.0.1:8080); non-loopback addresses require $PIXELA_MCP_AUTH_TOKEN")
+	configPath := flag.String("config", "", "config file (default: $PIXELA_MCP_CONFIG, or pixela-mcp/config.json in the user config directory)")
+	profilesPath := flag.String("profiles", "", "credential profiles file (default: $PIXELA_MCP_PROFILES, or pixela-mcp/profiles.json in the user config directory)")
+	readOnly := flag.Bool("read-only", false, "only allow tools that do not change anything on Pixela")
+	allowTools := flag.String("allow-tools", "", "comma-separated names of the only tools to allow")
+	denyTools := flag.String("deny-tools", "", "comma-separated names of tools to deny")
+	flag.Parse()
+
+	required := true
+	if *configPath == "" {
+		*configPath = os.Getenv("PIXELA_MCP_CONFIG")
+	}
+	if *configPath == "" {
+		*configPath = defaultConfigPath()
+		required = false
+	}
+	config, err := LoadConfig(*configPath, required)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	// Flags tighten the policy from the config file; an allow list given as
+	// a flag replaces the configured one
+	config.ReadOnly = config.ReadOnly || *readOnly
+	if *allowTools != "" {
+		config.AllowTools = splitToolList(*allowTools)
+	}
+	config.DenyTools = append(config.DenyTools, splitToolList(*denyTools)...)
+
+	required = true
+	if *profilesPath == "" {
+		*profilesPath = os.Getenv("PIXELA_MCP_PROFILES")
+	}
+	if *profilesPath == "" {
+		*profilesPath = defaultProfilesPath()
+		required = false
+	}
+	config.Profiles, err = LoadProfiles(*profilesPath, required)
+	if err != nil {
+		log.Fatalf("Failed to load profiles: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	port := os.Getenv("PORT")
+	if *transport == "" {
+		*transport = "stdio"
+		if port != "" {
+			*transport = "http"
+		}
+	}
+	if *addr == "" {
+		*addr = "127.0.0.1:8080"
+		if port != "" {
+			*addr = "127.0.0.1:" + port
+		}
+	}
+
+	redactor.SetConfigured(config.Secrets)
+	go config.Profiles.Watch(ctx)
+
+	tools := newToolRegistry()
+	if err := config.Policy.Check(tools); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	switch *transport {
+	case "stdio":
+		server := NewMCPServer(tools, config, newStdioTransport(os.Stdout))
+		server.run(ctx, os.Stdin)
+	case "http":
+		if err := serveHTTP(ctx, *addr, os.Getenv("PIXELA_MCP_AUTH_TOKEN"), tools, config); err != nil {
+			log.Fatalf("HTTP server failed: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown transport %q (want stdio or http)", *transport)
+	}
 }
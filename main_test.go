@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testTimeout bounds every wait for a message from the server.
+const testTimeout = 5 * time.Second
+
+// chanTransport hands every message the server sends to the test, decoded
+// as generic JSON.
+type chanTransport chan map[string]interface{}
+
+func (c chanTransport) Send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	c <- decoded
+	return nil
+}
+
+// testSession drives an MCPServer through its stdio loop.
+type testSession struct {
+	t      *testing.T
+	server *MCPServer
+	in     *io.PipeWriter
+	out    chanTransport
+}
+
+func newTestSession(t *testing.T, config *Config) *testSession {
+	t.Helper()
+	r, w := io.Pipe()
+	out := make(chanTransport, 64)
+	session := &testSession{
+		t:      t,
+		server: NewMCPServer(newToolRegistry(), config, out),
+		in:     w,
+		out:    out,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		session.server.run(context.Background(), r)
+	}()
+	t.Cleanup(func() {
+		w.Close()
+		<-done
+	})
+	return session
+}
+
+func newTestConfig() *Config {
+	return &Config{Profiles: newProfileStore("")}
+}
+
+// newProfileConfig returns a config with a single profile "test" for user
+// alice on the Pixela at baseURL.
+func newProfileConfig(t *testing.T, baseURL string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	writeProfiles(t, path, map[string]Profile{
+		"test": {Username: "alice", Token: "secret-token", BaseURL: baseURL},
+	})
+
+	config := newTestConfig()
+	var err error
+	if config.Profiles, err = LoadProfiles(path, true); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func writeProfiles(t *testing.T, path string, profiles map[string]Profile) {
+	t.Helper()
+	data, err := json.Marshal(profiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newFakePixela serves handler as a stand-in for the Pixela API and returns
+// its base URL.
+func newFakePixela(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// blockingPixela returns a fake Pixela whose requests are reported on
+// arrived and then hang until the client gives up or release is closed.
+func blockingPixela(t *testing.T) (baseURL string, arrived <-chan struct{}, release chan struct{}) {
+	t.Helper()
+	arrivals := make(chan struct{}, 64)
+	release = make(chan struct{})
+	baseURL = newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		arrivals <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+			w.Write([]byte(`{"graphs":[]}`))
+		}
+	})
+	return baseURL, arrivals, release
+}
+
+// waitFor fails unless ch delivers within testTimeout.
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// send writes one message to the server.
+func (s *testSession) send(message interface{}) {
+	s.t.Helper()
+	data, err := json.Marshal(message)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(s.in, "%s\n", data); err != nil {
+		s.t.Fatalf("writing to the server: %v", err)
+	}
+}
+
+// request sends a request with a numeric id.
+func (s *testSession) request(id int, method string, params interface{}) {
+	s.t.Helper()
+	message := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	s.send(message)
+}
+
+// notify sends a notification.
+func (s *testSession) notify(method string, params interface{}) {
+	s.t.Helper()
+	message := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	s.send(message)
+}
+
+// receive returns the next message from the server.
+func (s *testSession) receive() map[string]interface{} {
+	s.t.Helper()
+	select {
+	case message := <-s.out:
+		return message
+	case <-time.After(testTimeout):
+		s.t.Fatal("timed out waiting for a message from the server")
+		return nil
+	}
+}
+
+// expectSilence fails if the server sends anything within d.
+func (s *testSession) expectSilence(d time.Duration) {
+	s.t.Helper()
+	select {
+	case message := <-s.out:
+		s.t.Errorf("server sent %v, want nothing", message)
+	case <-time.After(d):
+	}
+}
+
+// initialize completes the handshake for protocolVersion with the given
+// client capabilities.
+func (s *testSession) initialize(protocolVersion string, capabilities map[string]interface{}) map[string]interface{} {
+	s.t.Helper()
+	if capabilities == nil {
+		capabilities = map[string]interface{}{}
+	}
+	s.request(0, "initialize", map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities":    capabilities,
+		"clientInfo":      map[string]interface{}{"name": "test", "version": "1"},
+	})
+	response := s.receive()
+	result, ok := response["result"].(map[string]interface{})
+	if !ok {
+		s.t.Fatalf("initialize response = %v, want a result", response)
+	}
+	s.notify("notifications/initialized", nil)
+	return result
+}
+
+// call runs a tool and returns its result, failing on a JSON-RPC error.
+func (s *testSession) call(id int, name string, arguments map[string]interface{}) map[string]interface{} {
+	s.t.Helper()
+	s.request(id, "tools/call", map[string]interface{}{"name": name, "arguments": arguments})
+	response := s.receive()
+	result, ok := response["result"].(map[string]interface{})
+	if !ok {
+		s.t.Fatalf("%s response = %v, want a result", name, response)
+	}
+	return result
+}
+
+// errorCode returns the code of a JSON-RPC error response, or 0.
+func errorCode(response map[string]interface{}) int {
+	err, _ := response["error"].(map[string]interface{})
+	code, _ := err["code"].(float64)
+	return int(code)
+}
+
+func TestLifecycle(t *testing.T) {
+	session := newTestSession(t, newTestConfig())
+
+	session.request(1, "tools/list", nil)
+	if response := session.receive(); errorCode(response) != errCodeNotInitialized {
+		t.Errorf("tools/list before initialize = %v, want error %d", response, errCodeNotInitialized)
+	}
+	session.request(2, "ping", nil)
+	if response := session.receive(); response["result"] == nil {
+		t.Errorf("ping before initialize = %v, want a result", response)
+	}
+
+	session.initialize("2025-06-18", nil)
+
+	session.request(3, "tools/list", nil)
+	response := session.receive()
+	result, _ := response["result"].(map[string]interface{})
+	if tools, _ := result["tools"].([]interface{}); len(tools) == 0 {
+		t.Errorf("tools/list after initialize = %v, want the tools", response)
+	}
+
+	session.send(map[string]interface{}{"jsonrpc": "1.0", "id": 4, "method": "tools/list"})
+	if response := session.receive(); errorCode(response) != errCodeInvalidRequest {
+		t.Errorf("jsonrpc 1.0 request = %v, want error %d", response, errCodeInvalidRequest)
+	}
+}
+
+func TestLifecycleChecksRequestsInArrivalOrder(t *testing.T) {
+	session := newTestSession(t, newTestConfig())
+
+	// The initialize right behind it must not let the early call through
+	session.request(1, "tools/call", map[string]interface{}{"name": "list_profiles"})
+	session.request(2, "initialize", map[string]interface{}{"protocolVersion": "2025-06-18", "capabilities": map[string]interface{}{}})
+
+	for i := 0; i < 2; i++ {
+		response := session.receive()
+		switch id := response["id"]; id {
+		case 1.0:
+			if errorCode(response) != errCodeNotInitialized {
+				t.Errorf("early tools/call = %v, want error %d", response, errCodeNotInitialized)
+			}
+		case 2.0:
+			if response["result"] == nil {
+				t.Errorf("initialize = %v, want a result", response)
+			}
+		default:
+			t.Errorf("unexpected response %v", response)
+		}
+	}
+}
+
+func TestCancelledCallIsNotAnswered(t *testing.T) {
+	baseURL, arrived, _ := blockingPixela(t)
+	session := newTestSession(t, newProfileConfig(t, baseURL))
+	session.initialize("2025-06-18", nil)
+
+	session.request(1, "tools/call", map[string]interface{}{"name": "get_graphs", "arguments": map[string]interface{}{"profile": "test"}})
+	waitFor(t, arrived, "the call to reach Pixela")
+	session.notify("notifications/cancelled", map[string]interface{}{"requestId": 1, "reason": "user gave up"})
+	session.expectSilence(200 * time.Millisecond)
+
+	// The server is still responsive and answers the next request first
+	session.request(2, "ping", nil)
+	if response := session.receive(); response["id"] != 2.0 {
+		t.Errorf("got %v, want the response to ping", response)
+	}
+}
+
+func TestConcurrentCallsAreBounded(t *testing.T) {
+	baseURL, arrived, release := blockingPixela(t)
+	session := newTestSession(t, newProfileConfig(t, baseURL))
+	session.initialize("2025-06-18", nil)
+
+	for id := 1; id <= maxConcurrentRequests+1; id++ {
+		session.request(id, "tools/call", map[string]interface{}{"name": "get_graphs", "arguments": map[string]interface{}{"profile": "test"}})
+	}
+	for i := 0; i < maxConcurrentRequests; i++ {
+		waitFor(t, arrived, "a call to reach Pixela")
+	}
+	select {
+	case <-arrived:
+		t.Fatalf("more than %d calls reached Pixela at once", maxConcurrentRequests)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	for i := 0; i <= maxConcurrentRequests; i++ {
+		if response := session.receive(); response["result"] == nil {
+			t.Errorf("response = %v, want a result", response)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxSummaryLength caps the page text returned by the page tools, which is
+// meant to be read by the model rather than shown in full.
+const maxSummaryLength = 4000
+
+var (
+	pageTitlePattern       = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	pageDescriptionPattern = regexp.MustCompile(`(?is)<meta\s[^>]*(?:name|property)=["'](?:og:)?description["'][^>]*content=["']([^"']*)["']`)
+	pageBreakPattern       = regexp.MustCompile(`(?i)<(br|/?p|/?div|/?li|/?ul|/?ol|/?tr|/?table|/?h[1-6]|/?section|/?article|/?header|/?footer|/?dt|/?dd)\b[^>]*>`)
+	pageTagPattern         = regexp.MustCompile(`(?s)<[^>]*>`)
+	pageLinkPattern        = regexp.MustCompile(`(?is)<a\s[^>]*href=["'](https?://[^"']+)["']`)
+	pageCommentPattern     = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+	// pageHiddenPatterns match comments and elements whose content is not
+	// page text.
+	pageHiddenPatterns = []*regexp.Regexp{
+		pageCommentPattern,
+		hiddenElementPattern("head"),
+		hiddenElementPattern("script"),
+		hiddenElementPattern("style"),
+		hiddenElementPattern("noscript"),
+		hiddenElementPattern("svg"),
+		hiddenElementPattern("template"),
+	}
+)
+
+func hiddenElementPattern(tag string) *regexp.Regexp {
+	return regexp.MustCompile(`(?is)<` + tag + `\b.*?</` + tag + `\s*>`)
+}
+
+// summarizePage extracts the title and the visible text of an HTML page, one
+// line per block, without scripts, styles or inline SVG.
+func summarizePage(page string) (title, summary string) {
+	if m := pageTitlePattern.FindStringSubmatch(page); m != nil {
+		title = collapseSpaces(html.UnescapeString(m[1]))
+	}
+
+	var lines []string
+	if m := pageDescriptionPattern.FindStringSubmatch(page); m != nil {
+		if description := collapseSpaces(html.UnescapeString(m[1])); description != "" {
+			lines = append(lines, description)
+		}
+	}
+
+	text := page
+	for _, pattern := range pageHiddenPatterns {
+		text = pattern.ReplaceAllString(text, "")
+	}
+	text = pageBreakPattern.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(pageTagPattern.ReplaceAllString(text, " "))
+	for _, line := range strings.Split(text, "\n") {
+		if line = collapseSpaces(line); line != "" && (len(lines) == 0 || lines[len(lines)-1] != line) {
+			lines = append(lines, line)
+		}
+	}
+
+	summary = strings.Join(lines, "\n")
+	if len(summary) > maxSummaryLength {
+		cut := maxSummaryLength
+		// Do not cut a UTF-8 sequence in half
+		for cut > 0 && summary[cut]&0xc0 == 0x80 {
+			cut--
+		}
+		summary = summary[:cut] + "…"
+	}
+	return title, summary
+}
+
+// pageLinks returns the distinct absolute links of an HTML page that point
+// away from host, in page order. Commented-out links are not on the page.
+func pageLinks(page, host string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	page = pageCommentPattern.ReplaceAllString(page, "")
+	for _, m := range pageLinkPattern.FindAllStringSubmatch(page, -1) {
+		link := html.UnescapeString(m[1])
+		u, err := url.Parse(link)
+		if err != nil || strings.EqualFold(u.Hostname(), host) || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Pixela documents no markup for the profile page, so reading a profile
+// from it rests on these assumptions: the display name is the first h1 (or
+// else the page title), the title and the about URL sit in elements with a
+// title and an about class, the other outbound links outside the header,
+// navigation and footer are the contribute URLs, and the graph is the first
+// graph URL of the user anywhere in the page.
+var (
+	profileNamePattern      = regexp.MustCompile(`(?is)<h1\b[^>]*>(.*?)</h1\s*>`)
+	profileTitlePattern     = classElementPattern("title")
+	profileAboutPattern     = classElementPattern("about")
+	profilePageTitlePattern = regexp.MustCompile(`^(.*?)\s*(?:\(@[^)]*\))?\s*(?:[|-]\s*Pixela)?$`)
+	profileGraphPattern     = regexp.MustCompile(`/v1/users/([a-z][a-z0-9-]{1,32})/graphs/([a-z][a-z0-9-]{1,16})\b`)
+	profileChromePatterns   = []*regexp.Regexp{
+		hiddenElementPattern("header"),
+		hiddenElementPattern("nav"),
+		hiddenElementPattern("footer"),
+	}
+)
+
+// classElementPattern matches a text element carrying class among its
+// classes and captures its content.
+func classElementPattern(class string) *regexp.Regexp {
+	const tags = `(?:p|div|span|dd|li|h[2-6])`
+	return regexp.MustCompile(`(?is)<` + tags + `\s[^>]*class=["'](?:[^"']*\s)?` + class + `(?:\s[^"']*)?["'][^>]*>(.*?)</` + tags + `\s*>`)
+}
+
+// profileFromPage reads the profile of username from the HTML of the
+// profile page, whose links to host are Pixela's own. Gravatar addresses
+// and timezones are not shown on the page and are never read.
+func profileFromPage(page, host, username string) UserProfileOutput {
+	var profile UserProfileOutput
+	page = pageCommentPattern.ReplaceAllString(page, "")
+	if m := profileNamePattern.FindStringSubmatch(page); m != nil {
+		profile.DisplayName = elementText(m[1])
+	} else if m := pageTitlePattern.FindStringSubmatch(page); m != nil {
+		title := collapseSpaces(html.UnescapeString(m[1]))
+		profile.DisplayName = profilePageTitlePattern.FindStringSubmatch(title)[1]
+	}
+	if m := profileTitlePattern.FindStringSubmatch(page); m != nil {
+		profile.Title = elementText(m[1])
+	}
+	for _, m := range profileGraphPattern.FindAllStringSubmatch(page, -1) {
+		if m[1] == username {
+			profile.PixelaGraph = m[2]
+			break
+		}
+	}
+
+	body := page
+	for _, pattern := range append(profileChromePatterns, pageHiddenPatterns...) {
+		body = pattern.ReplaceAllString(body, "")
+	}
+	if m := profileAboutPattern.FindStringSubmatch(body); m != nil {
+		if links := pageLinks(m[1], host); len(links) > 0 {
+			profile.AboutURL = links[0]
+		}
+	}
+	for _, link := range pageLinks(body, host) {
+		if link != profile.AboutURL {
+			profile.ContributeURLs = append(profile.ContributeURLs, link)
+		}
+	}
+	return profile
+}
+
+// elementText returns the text of an HTML fragment on one line.
+func elementText(fragment string) string {
+	return collapseSpaces(html.UnescapeString(pageTagPattern.ReplaceAllString(fragment, " ")))
+}
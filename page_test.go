@@ -0,0 +1,220 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// The pages in testdata follow the layout of the Pixela profile and graph
+// pages, trimmed to the parts the page tools read.
+func readPage(t *testing.T, name string) string {
+	t.Helper()
+	page, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(page)
+}
+
+func TestSummarizePage(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantTitle   string
+		wantSummary []string
+	}{
+		{
+			fixture:   "profile_page.html",
+			wantTitle: "Alice Example (@alice) | Pixela",
+			wantSummary: []string{
+				"Alice's profile on Pixela",
+				"Pixela",
+				"Alice Example",
+				"Walker & runner",
+				"https://alice.example.com/about",
+				"github.com/alice",
+				"Blog",
+				"GitHub © Pixela",
+			},
+		},
+		{
+			fixture:   "graph_page.html",
+			wantTitle: "Steps | Pixela",
+			wantSummary: []string{
+				"Daily steps of alice",
+				"Steps",
+				"Total 123,456 steps",
+				"Max 12,000 steps",
+				"Powered by Pixela and our sponsor",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			title, summary := summarizePage(readPage(t, tt.fixture))
+			if title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", title, tt.wantTitle)
+			}
+			if want := strings.Join(tt.wantSummary, "\n"); summary != want {
+				t.Errorf("summary = %q, want %q", summary, want)
+			}
+		})
+	}
+}
+
+func TestSummarizePageTruncates(t *testing.T) {
+	_, summary := summarizePage("<p>" + strings.Repeat("ピクセラ", maxSummaryLength) + "</p>")
+	if !strings.HasSuffix(summary, "…") || len(summary) > maxSummaryLength+len("…") {
+		t.Errorf("summary of %d bytes, want at most %d ending in …", len(summary), maxSummaryLength)
+	}
+	if !utf8.ValidString(summary) {
+		t.Error("summary was cut inside a UTF-8 sequence")
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		// The about URL, then the contribute URLs; the links back to
+		// Pixela and the commented-out link are not listed
+		{"profile_page.html", []string{
+			"https://alice.example.com/about",
+			"https://github.com/alice",
+			"https://alice.example.com/blog?tag=walk&page=1",
+		}},
+		{"graph_page.html", []string{"http://example.org/sponsor"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			if got := pageLinks(readPage(t, tt.fixture), "pixe.la"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pageLinks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetGraphPageFetch(t *testing.T) {
+	var gotPath string
+	page := readPage(t, "graph_page.html")
+	baseURL := newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	session := newTestSession(t, newProfileConfig(t, baseURL))
+	session.initialize("2025-06-18", nil)
+
+	result := session.call(1, "get_graph_page", map[string]interface{}{"profile": "test", "username": "alice", "graphID": "steps", "fetch": true})
+	if result["isError"] == true {
+		t.Fatalf("get_graph_page = %v, want success", result)
+	}
+	if gotPath != "/v1/users/alice/graphs/steps.html" {
+		t.Errorf("Pixela got %s, want the graph page", gotPath)
+	}
+	output, _ := result["structuredContent"].(map[string]interface{})
+	if output["url"] != baseURL+"/v1/users/alice/graphs/steps.html" || output["title"] != "Steps | Pixela" {
+		t.Errorf("structuredContent = %v, want the page URL and title", output)
+	}
+	if summary, _ := output["summary"].(string); !strings.Contains(summary, "Total 123,456 steps") {
+		t.Errorf("summary = %q, want the stats of the graph", summary)
+	}
+	links, _ := output["links"].([]interface{})
+	if len(links) == 0 || links[len(links)-1] != "http://example.org/sponsor" {
+		t.Errorf("links = %v, want the sponsor link last", links)
+	}
+}
+
+func TestProfileFromPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		page     string
+		want     UserProfileOutput
+	}{
+		{
+			name:     "saved profile page",
+			username: "alice",
+			page:     readPage(t, "profile_page.html"),
+			want: UserProfileOutput{
+				DisplayName:    "Alice Example",
+				Title:          "Walker & runner",
+				AboutURL:       "https://alice.example.com/about",
+				ContributeURLs: []string{"https://github.com/alice", "https://alice.example.com/blog?tag=walk&page=1"},
+				PixelaGraph:    "steps",
+			},
+		},
+		{
+			// Without an h1 the display name comes from the page title, and
+			// without an about element every link is a contribute URL
+			name:     "sparse page",
+			username: "bob",
+			page: `<html><head><title>Bob (@bob) | Pixela</title></head><body>
+<img src="https://pixe.la/v1/users/carol/graphs/reads"><img src="https://pixe.la/v1/users/bob/graphs/runs?mode=short">
+<a href="https://bob.example.com/">Bob's site</a></body></html>`,
+			want: UserProfileOutput{
+				DisplayName:    "Bob",
+				ContributeURLs: []string{"https://bob.example.com/"},
+				PixelaGraph:    "runs",
+			},
+		},
+		{
+			name:     "empty profile",
+			username: "carol",
+			page:     `<html><head><title>carol | Pixela</title></head><body><header><a href="https://github.com/a-know/Pixela">GitHub</a></header></body></html>`,
+			want:     UserProfileOutput{DisplayName: "carol"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := profileFromPage(tt.page, "pixe.la", tt.username); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("profileFromPage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUserProfile(t *testing.T) {
+	var baseURL, gotPath, gotToken string
+	page := readPage(t, "profile_page.html")
+	baseURL = newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotToken = r.URL.Path, r.Header.Get("X-USER-TOKEN")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// The page links to Pixela on the host that serves it
+		w.Write([]byte(strings.ReplaceAll(page, "https://pixe.la", baseURL)))
+	})
+	session := newTestSession(t, newProfileConfig(t, baseURL))
+	session.initialize("2025-06-18", nil)
+
+	tools := listTools(session, 1)
+	if requiresProperty(tools["get_user_profile"], "token") {
+		t.Error("get_user_profile requires a token, which the public page does not need")
+	}
+	if tools["get_user_profile"]["outputSchema"] == nil {
+		t.Error("get_user_profile has no outputSchema")
+	}
+
+	result := session.call(2, "get_user_profile", map[string]interface{}{"profile": "test", "username": "alice"})
+	if result["isError"] == true {
+		t.Fatalf("get_user_profile = %v, want success", result)
+	}
+	if gotPath != "/@alice" || gotToken != "" {
+		t.Errorf("Pixela got %s with token %q, want the profile page without a token", gotPath, gotToken)
+	}
+	want := map[string]interface{}{
+		"url":            baseURL + "/@alice",
+		"displayName":    "Alice Example",
+		"title":          "Walker & runner",
+		"aboutURL":       "https://alice.example.com/about",
+		"contributeURLs": []interface{}{"https://github.com/alice", "https://alice.example.com/blog?tag=walk&page=1"},
+		"pixelaGraph":    "steps",
+	}
+	if got := result["structuredContent"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("structuredContent = %v, want %v", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package pixela
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,9 +15,14 @@ const (
 	BaseURL = "https://pixe.la"
 )
 
+// Client calls the Pixela API. Every API method has a Context variant
+// (e.g. PostPixelContext) that binds the HTTP request to ctx for
+// cancellation and deadlines; the plain method uses context.Background().
+// Failures reported by Pixela are returned as *APIError.
 type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	Retry      RetryPolicy
 }
 
 type CreateUserRequest struct {
@@ -34,8 +40,9 @@ type CreateGraphRequest struct {
 	Color               string `json:"color"`
 	Timezone            string `json:"timezone,omitempty"`
 	SelfSufficient      string `json:"selfSufficient,omitempty"`
-	IsSecret            string `json:"isSecret,omitempty"`
-	PublishOptionalData string `json:"publishOptionalData,omitempty"`
+	IsSecret            *bool  `json:"isSecret,omitempty"`
+	PublishOptionalData *bool  `json:"publishOptionalData,omitempty"`
+	StartOnMonday       *bool  `json:"startOnMonday,omitempty"`
 }
 
 type PostPixelRequest struct {
@@ -66,29 +73,104 @@ type GetWebhooksResponse struct {
 	Webhooks []Webhook `json:"webhooks"`
 }
 
+// Channel is a destination for graph notifications. Pixela only supports
+// channels of type "slack".
+type Channel struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	Detail ChannelDetail `json:"detail"`
+}
+
+// ChannelDetail holds the settings of a slack channel. URL is the Incoming
+// Webhook URL of the Slack channel and is a secret.
+type ChannelDetail struct {
+	URL         string `json:"url"`
+	UserName    string `json:"userName"`
+	ChannelName string `json:"channelName"`
+}
+
+type CreateChannelRequest struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	Detail ChannelDetail `json:"detail"`
+}
+
+type UpdateChannelRequest struct {
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	Detail ChannelDetail `json:"detail"`
+}
+
+type GetChannelsResponse struct {
+	Channels []Channel `json:"channels"`
+}
+
+// Notification is a rule that notifies a channel when the pixel of the day
+// meets a condition. Condition is one of ">", "=", "<" or "multipleOf";
+// RemindBy is the hour (0-23) by which a notification for an unmet
+// condition is sent.
+type Notification struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Target    string      `json:"target"`
+	Condition string      `json:"condition"`
+	Threshold json.Number `json:"threshold"`
+	RemindBy  string      `json:"remindBy,omitempty"`
+	ChannelID string      `json:"channelID"`
+}
+
+type CreateNotificationRequest struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Target    string `json:"target"`
+	Condition string `json:"condition"`
+	Threshold string `json:"threshold"`
+	RemindBy  string `json:"remindBy,omitempty"`
+	ChannelID string `json:"channelID"`
+}
+
+// UpdateNotificationRequest holds the full settings of a rule, which
+// replace the current ones.
+type UpdateNotificationRequest struct {
+	Name      string `json:"name"`
+	Target    string `json:"target"`
+	Condition string `json:"condition"`
+	Threshold string `json:"threshold"`
+	RemindBy  string `json:"remindBy"`
+	ChannelID string `json:"channelID"`
+}
+
+type GetNotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+}
+
 type UpdateUserRequest struct {
 	NewToken   string `json:"newToken"`
 	ThanksCode string `json:"thanksCode,omitempty"`
 }
 
 type UpdateUserProfileRequest struct {
-	DisplayName string `json:"displayName,omitempty"`
-	ProfileURL  string `json:"profileURL,omitempty"`
-	Description string `json:"description,omitempty"`
-	AvatarURL   string `json:"avatarURL,omitempty"`
-	Twitter     string `json:"twitter,omitempty"`
-	GitHub      string `json:"github,omitempty"`
-	Website     string `json:"website,omitempty"`
+	DisplayName       string   `json:"displayName,omitempty"`
+	GravatarIconEmail string   `json:"gravatarIconEmail,omitempty"`
+	Title             string   `json:"title,omitempty"`
+	Timezone          string   `json:"timezone,omitempty"`
+	AboutURL          string   `json:"aboutURL,omitempty"`
+	ContributeURLs    []string `json:"contributeURLs,omitempty"`
+	PixelaGraph       string   `json:"pixelaGraph,omitempty"`
 }
 
 type UpdateGraphRequest struct {
-	Name                string `json:"name,omitempty"`
-	Unit                string `json:"unit,omitempty"`
-	Color               string `json:"color,omitempty"`
-	Timezone            string `json:"timezone,omitempty"`
-	SelfSufficient      string `json:"selfSufficient,omitempty"`
-	IsSecret            string `json:"isSecret,omitempty"`
-	PublishOptionalData string `json:"publishOptionalData,omitempty"`
+	Name                string   `json:"name,omitempty"`
+	Unit                string   `json:"unit,omitempty"`
+	Color               string   `json:"color,omitempty"`
+	Timezone            string   `json:"timezone,omitempty"`
+	PurgeCacheURLs      []string `json:"purgeCacheURLs,omitempty"`
+	SelfSufficient      string   `json:"selfSufficient,omitempty"`
+	IsSecret            *bool    `json:"isSecret,omitempty"`
+	PublishOptionalData *bool    `json:"publishOptionalData,omitempty"`
+	StartOnMonday       *bool    `json:"startOnMonday,omitempty"`
 }
 
 type Pixel struct {
@@ -166,9 +248,10 @@ type GraphDefinition struct {
 	Type                string     `json:"type"`
 	Color               string     `json:"color"`
 	Timezone            string     `json:"timezone,omitempty"`
-	SelfSufficient      BoolString `json:"selfSufficient"`
+	SelfSufficient      string     `json:"selfSufficient"`
 	IsSecret            BoolString `json:"isSecret"`
 	PublishOptionalData BoolString `json:"publishOptionalData"`
+	StartOnMonday       BoolString `json:"startOnMonday"`
 }
 
 type GetGraphsResponse struct {
@@ -176,8 +259,9 @@ type GetGraphsResponse struct {
 }
 
 type PixelaResponse struct {
-	Message   string `json:"message"`
-	IsSuccess bool   `json:"isSuccess"`
+	Message    string `json:"message"`
+	IsSuccess  bool   `json:"isSuccess"`
+	IsRejected bool   `json:"isRejected,omitempty"`
 }
 
 func NewClient() *Client {
@@ -186,20 +270,33 @@ func NewClient() *Client {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Retry: DefaultRetryPolicy(),
 	}
 }
 
 func (c *Client) CreateUser(req CreateUserRequest) (*PixelaResponse, error) {
+	return c.CreateUserContext(context.Background(), req)
+}
+
+func (c *Client) CreateUserContext(ctx context.Context, req CreateUserRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Post(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
 		fmt.Sprintf("%s/v1/users", c.BaseURL),
-		"application/json",
 		bytes.NewBuffer(jsonData),
 	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -209,12 +306,17 @@ func (c *Client) CreateUser(req CreateUserRequest) (*PixelaResponse, error) {
 }
 
 func (c *Client) CreateGraph(username, token string, req CreateGraphRequest) (*PixelaResponse, error) {
+	return c.CreateGraphContext(context.Background(), username, token, req)
+}
+
+func (c *Client) CreateGraphContext(ctx context.Context, username, token string, req CreateGraphRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/v1/users/%s/graphs", c.BaseURL, username),
 		bytes.NewBuffer(jsonData),
@@ -226,7 +328,7 @@ func (c *Client) CreateGraph(username, token string, req CreateGraphRequest) (*P
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create graph: %w", err)
 	}
@@ -236,12 +338,17 @@ func (c *Client) CreateGraph(username, token string, req CreateGraphRequest) (*P
 }
 
 func (c *Client) PostPixel(username, token, graphID string, req PostPixelRequest) (*PixelaResponse, error) {
+	return c.PostPixelContext(context.Background(), username, token, graphID, req)
+}
+
+func (c *Client) PostPixelContext(ctx context.Context, username, token, graphID string, req PostPixelRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s", c.BaseURL, username, graphID),
 		bytes.NewBuffer(jsonData),
@@ -253,7 +360,7 @@ func (c *Client) PostPixel(username, token, graphID string, req PostPixelRequest
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to post pixel: %w", err)
 	}
@@ -267,12 +374,17 @@ func (c *Client) PostPixel(username, token, graphID string, req PostPixelRequest
 // POST /v1/users/<username>/graphs/<graphID>/pixels
 
 func (c *Client) BatchPostPixels(username, token, graphID string, pixels []PostPixelRequest) (*PixelaResponse, error) {
+	return c.BatchPostPixelsContext(context.Background(), username, token, graphID, pixels)
+}
+
+func (c *Client) BatchPostPixelsContext(ctx context.Context, username, token, graphID string, pixels []PostPixelRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(pixels)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/pixels", c.BaseURL, username, graphID),
 		bytes.NewBuffer(jsonData),
@@ -284,7 +396,7 @@ func (c *Client) BatchPostPixels(username, token, graphID string, pixels []PostP
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to post pixels: %w", err)
 	}
@@ -294,7 +406,12 @@ func (c *Client) BatchPostPixels(username, token, graphID string, pixels []PostP
 }
 
 func (c *Client) GetPixel(username, token, graphID, date string) (*Pixel, error) {
-	httpReq, err := http.NewRequest(
+	return c.GetPixelContext(context.Background(), username, token, graphID, date)
+}
+
+func (c *Client) GetPixelContext(ctx context.Context, username, token, graphID, date string) (*Pixel, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/%s", c.BaseURL, username, graphID, date),
 		nil,
@@ -305,15 +422,14 @@ func (c *Client) GetPixel(username, token, graphID, date string) (*Pixel, error)
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pixel: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get pixel: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var pixel Pixel
@@ -325,7 +441,12 @@ func (c *Client) GetPixel(username, token, graphID, date string) (*Pixel, error)
 }
 
 func (c *Client) GetLatestPixel(username, token, graphID string) (*Pixel, error) {
-	httpReq, err := http.NewRequest(
+	return c.GetLatestPixelContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) GetLatestPixelContext(ctx context.Context, username, token, graphID string) (*Pixel, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/latest", c.BaseURL, username, graphID),
 		nil,
@@ -336,15 +457,14 @@ func (c *Client) GetLatestPixel(username, token, graphID string) (*Pixel, error)
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get latest pixel: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get latest pixel: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var pixel Pixel
@@ -356,6 +476,10 @@ func (c *Client) GetLatestPixel(username, token, graphID string) (*Pixel, error)
 }
 
 func (c *Client) GetTodayPixel(username, token, graphID string, returnEmpty *bool) (*Pixel, error) {
+	return c.GetTodayPixelContext(context.Background(), username, token, graphID, returnEmpty)
+}
+
+func (c *Client) GetTodayPixelContext(ctx context.Context, username, token, graphID string, returnEmpty *bool) (*Pixel, error) {
 	baseURL := fmt.Sprintf("%s/v1/users/%s/graphs/%s/today", c.BaseURL, username, graphID)
 	u, err := url.Parse(baseURL)
 	if err != nil {
@@ -368,7 +492,8 @@ func (c *Client) GetTodayPixel(username, token, graphID string, returnEmpty *boo
 		u.RawQuery = q.Encode()
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		u.String(),
 		nil,
@@ -379,15 +504,14 @@ func (c *Client) GetTodayPixel(username, token, graphID string, returnEmpty *boo
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get today pixel: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get today pixel: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var pixel Pixel
@@ -399,7 +523,12 @@ func (c *Client) GetTodayPixel(username, token, graphID string, returnEmpty *boo
 }
 
 func (c *Client) DeleteUser(username, token string) (*PixelaResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.DeleteUserContext(context.Background(), username, token)
+}
+
+func (c *Client) DeleteUserContext(ctx context.Context, username, token string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"DELETE",
 		fmt.Sprintf("%s/v1/users/%s", c.BaseURL, username),
 		nil,
@@ -410,7 +539,7 @@ func (c *Client) DeleteUser(username, token string) (*PixelaResponse, error) {
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -420,12 +549,17 @@ func (c *Client) DeleteUser(username, token string) (*PixelaResponse, error) {
 }
 
 func (c *Client) UpdatePixel(username, token, graphID, date string, req UpdatePixelRequest) (*PixelaResponse, error) {
+	return c.UpdatePixelContext(context.Background(), username, token, graphID, date, req)
+}
+
+func (c *Client) UpdatePixelContext(ctx context.Context, username, token, graphID, date string, req UpdatePixelRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/%s", c.BaseURL, username, graphID, date),
 		bytes.NewBuffer(jsonData),
@@ -437,7 +571,7 @@ func (c *Client) UpdatePixel(username, token, graphID, date string, req UpdatePi
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update pixel: %w", err)
 	}
@@ -447,7 +581,12 @@ func (c *Client) UpdatePixel(username, token, graphID, date string, req UpdatePi
 }
 
 func (c *Client) DeletePixel(username, token, graphID, date string) (*PixelaResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.DeletePixelContext(context.Background(), username, token, graphID, date)
+}
+
+func (c *Client) DeletePixelContext(ctx context.Context, username, token, graphID, date string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"DELETE",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/%s", c.BaseURL, username, graphID, date),
 		nil,
@@ -458,7 +597,7 @@ func (c *Client) DeletePixel(username, token, graphID, date string) (*PixelaResp
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete pixel: %w", err)
 	}
@@ -468,7 +607,12 @@ func (c *Client) DeletePixel(username, token, graphID, date string) (*PixelaResp
 }
 
 func (c *Client) IncrementPixel(username, token, graphID string) (*PixelaResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.IncrementPixelContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) IncrementPixelContext(ctx context.Context, username, token, graphID string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/increment", c.BaseURL, username, graphID),
 		nil,
@@ -480,7 +624,7 @@ func (c *Client) IncrementPixel(username, token, graphID string) (*PixelaRespons
 	httpReq.Header.Set("X-USER-TOKEN", token)
 	httpReq.Header.Set("Content-Length", "0")
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to increment pixel: %w", err)
 	}
@@ -490,7 +634,12 @@ func (c *Client) IncrementPixel(username, token, graphID string) (*PixelaRespons
 }
 
 func (c *Client) DecrementPixel(username, token, graphID string) (*PixelaResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.DecrementPixelContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) DecrementPixelContext(ctx context.Context, username, token, graphID string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/decrement", c.BaseURL, username, graphID),
 		nil,
@@ -502,7 +651,7 @@ func (c *Client) DecrementPixel(username, token, graphID string) (*PixelaRespons
 	httpReq.Header.Set("X-USER-TOKEN", token)
 	httpReq.Header.Set("Content-Length", "0")
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrement pixel: %w", err)
 	}
@@ -512,12 +661,17 @@ func (c *Client) DecrementPixel(username, token, graphID string) (*PixelaRespons
 }
 
 func (c *Client) CreateWebhook(username, token string, req CreateWebhookRequest) (*Webhook, error) {
+	return c.CreateWebhookContext(context.Background(), username, token, req)
+}
+
+func (c *Client) CreateWebhookContext(ctx context.Context, username, token string, req CreateWebhookRequest) (*Webhook, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/v1/users/%s/webhooks", c.BaseURL, username),
 		bytes.NewBuffer(jsonData),
@@ -529,15 +683,14 @@ func (c *Client) CreateWebhook(username, token string, req CreateWebhookRequest)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create webhook: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to create webhook: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var webhook Webhook
@@ -549,7 +702,12 @@ func (c *Client) CreateWebhook(username, token string, req CreateWebhookRequest)
 }
 
 func (c *Client) GetWebhooks(username, token string) (*GetWebhooksResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.GetWebhooksContext(context.Background(), username, token)
+}
+
+func (c *Client) GetWebhooksContext(ctx context.Context, username, token string) (*GetWebhooksResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/v1/users/%s/webhooks", c.BaseURL, username),
 		nil,
@@ -560,15 +718,14 @@ func (c *Client) GetWebhooks(username, token string) (*GetWebhooksResponse, erro
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get webhooks: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get webhooks: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var webhooksResponse GetWebhooksResponse
@@ -580,12 +737,17 @@ func (c *Client) GetWebhooks(username, token string) (*GetWebhooksResponse, erro
 }
 
 func (c *Client) UpdateUser(username, token string, req UpdateUserRequest) (*PixelaResponse, error) {
+	return c.UpdateUserContext(context.Background(), username, token, req)
+}
+
+func (c *Client) UpdateUserContext(ctx context.Context, username, token string, req UpdateUserRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/v1/users/%s", c.BaseURL, username),
 		bytes.NewBuffer(jsonData),
@@ -597,7 +759,7 @@ func (c *Client) UpdateUser(username, token string, req UpdateUserRequest) (*Pix
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
@@ -607,12 +769,17 @@ func (c *Client) UpdateUser(username, token string, req UpdateUserRequest) (*Pix
 }
 
 func (c *Client) UpdateUserProfile(username, token string, req UpdateUserProfileRequest) (*PixelaResponse, error) {
+	return c.UpdateUserProfileContext(context.Background(), username, token, req)
+}
+
+func (c *Client) UpdateUserProfileContext(ctx context.Context, username, token string, req UpdateUserProfileRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/@%s", c.BaseURL, username),
 		bytes.NewBuffer(jsonData),
@@ -624,7 +791,7 @@ func (c *Client) UpdateUserProfile(username, token string, req UpdateUserProfile
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update user profile: %w", err)
 	}
@@ -634,12 +801,17 @@ func (c *Client) UpdateUserProfile(username, token string, req UpdateUserProfile
 }
 
 func (c *Client) UpdateGraph(username, token, graphID string, req UpdateGraphRequest) (*PixelaResponse, error) {
+	return c.UpdateGraphContext(context.Background(), username, token, graphID, req)
+}
+
+func (c *Client) UpdateGraphContext(ctx context.Context, username, token, graphID string, req UpdateGraphRequest) (*PixelaResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s", c.BaseURL, username, graphID),
 		bytes.NewBuffer(jsonData),
@@ -651,7 +823,7 @@ func (c *Client) UpdateGraph(username, token, graphID string, req UpdateGraphReq
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update graph: %w", err)
 	}
@@ -661,7 +833,12 @@ func (c *Client) UpdateGraph(username, token, graphID string, req UpdateGraphReq
 }
 
 func (c *Client) DeleteGraph(username, token, graphID string) (*PixelaResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.DeleteGraphContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) DeleteGraphContext(ctx context.Context, username, token, graphID string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"DELETE",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s", c.BaseURL, username, graphID),
 		nil,
@@ -672,7 +849,7 @@ func (c *Client) DeleteGraph(username, token, graphID string) (*PixelaResponse,
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete graph: %w", err)
 	}
@@ -682,6 +859,10 @@ func (c *Client) DeleteGraph(username, token, graphID string) (*PixelaResponse,
 }
 
 func (c *Client) GetPixels(username, token, graphID string, from, to, withBody *string) (*GetPixelsResponse, error) {
+	return c.GetPixelsContext(context.Background(), username, token, graphID, from, to, withBody)
+}
+
+func (c *Client) GetPixelsContext(ctx context.Context, username, token, graphID string, from, to, withBody *string) (*GetPixelsResponse, error) {
 	baseURL := fmt.Sprintf("%s/v1/users/%s/graphs/%s/pixels", c.BaseURL, username, graphID)
 	u, err := url.Parse(baseURL)
 	if err != nil {
@@ -699,7 +880,8 @@ func (c *Client) GetPixels(username, token, graphID string, from, to, withBody *
 	}
 	u.RawQuery = q.Encode()
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		u.String(),
 		nil,
@@ -710,15 +892,14 @@ func (c *Client) GetPixels(username, token, graphID string, from, to, withBody *
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pixels: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get pixels: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var pixelsResp GetPixelsResponse
@@ -730,7 +911,12 @@ func (c *Client) GetPixels(username, token, graphID string, from, to, withBody *
 }
 
 func (c *Client) GetGraphStats(username, token, graphID string) (*GraphStats, error) {
-	httpReq, err := http.NewRequest(
+	return c.GetGraphStatsContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) GetGraphStatsContext(ctx context.Context, username, token, graphID string) (*GraphStats, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/stats", c.BaseURL, username, graphID),
 		nil,
@@ -741,15 +927,14 @@ func (c *Client) GetGraphStats(username, token, graphID string) (*GraphStats, er
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get graph stats: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get graph stats: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var stats GraphStats
@@ -761,7 +946,12 @@ func (c *Client) GetGraphStats(username, token, graphID string) (*GraphStats, er
 }
 
 func (c *Client) GetGraphs(username, token string) (*GetGraphsResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.GetGraphsContext(context.Background(), username, token)
+}
+
+func (c *Client) GetGraphsContext(ctx context.Context, username, token string) (*GetGraphsResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/v1/users/%s/graphs", c.BaseURL, username),
 		nil,
@@ -772,12 +962,16 @@ func (c *Client) GetGraphs(username, token string) (*GetGraphsResponse, error) {
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get graphs: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
@@ -792,7 +986,12 @@ func (c *Client) GetGraphs(username, token string) (*GetGraphsResponse, error) {
 }
 
 func (c *Client) GetGraphDefinition(username, token, graphID string) (*GraphDefinition, error) {
-	httpReq, err := http.NewRequest(
+	return c.GetGraphDefinitionContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) GetGraphDefinitionContext(ctx context.Context, username, token, graphID string) (*GraphDefinition, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/graph-def", c.BaseURL, username, graphID),
 		nil,
@@ -803,12 +1002,16 @@ func (c *Client) GetGraphDefinition(username, token, graphID string) (*GraphDefi
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get graph definition: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
@@ -822,15 +1025,136 @@ func (c *Client) GetGraphDefinition(username, token, graphID string) (*GraphDefi
 	return &graphDef, nil
 }
 
+// GraphRenderOptions are the query parameters that change how a graph SVG
+// is rendered. Empty fields are left out.
+type GraphRenderOptions struct {
+	Date        string // yyyyMMdd; the graph ends at this date
+	Mode        string // short, badge or line
+	Appearance  string // dark
+	LessThan    string // only show pixels with a quantity below this
+	GreaterThan string // only show pixels with a quantity above this
+}
+
+func (o GraphRenderOptions) query() url.Values {
+	q := url.Values{}
+	if o.Date != "" {
+		q.Set("date", o.Date)
+	}
+	if o.Mode != "" {
+		q.Set("mode", o.Mode)
+	}
+	if o.Appearance != "" {
+		q.Set("appearance", o.Appearance)
+	}
+	if o.LessThan != "" {
+		q.Set("lessThan", o.LessThan)
+	}
+	if o.GreaterThan != "" {
+		q.Set("greaterThan", o.GreaterThan)
+	}
+	return q
+}
+
+// GraphURL returns the URL of the graph SVG rendered with opts. It needs no
+// token, so it can be shared or embedded as is.
+func (c *Client) GraphURL(username, graphID string, opts GraphRenderOptions) string {
+	graphURL := fmt.Sprintf("%s/v1/users/%s/graphs/%s", c.BaseURL, username, graphID)
+	if q := opts.query(); len(q) > 0 {
+		graphURL += "?" + q.Encode()
+	}
+	return graphURL
+}
+
 func (c *Client) GetGraph(username, graphID string) (string, error) {
-	resp, err := c.HTTPClient.Get(fmt.Sprintf("%s/v1/users/%s/graphs/%s", c.BaseURL, username, graphID))
+	return c.GetGraphContext(context.Background(), username, graphID)
+}
+
+func (c *Client) GetGraphContext(ctx context.Context, username, graphID string) (string, error) {
+	return c.GetGraphWithOptionsContext(ctx, username, "", graphID, GraphRenderOptions{})
+}
+
+// GetGraphWithOptionsContext fetches the graph SVG rendered with opts. The
+// token is optional and only sent when given.
+func (c *Client) GetGraphWithOptionsContext(ctx context.Context, username, token, graphID string, opts GraphRenderOptions) (string, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		c.GraphURL(username, graphID, opts),
+		nil,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if token != "" {
+		httpReq.Header.Set("X-USER-TOKEN", token)
+	}
+
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return "", fmt.Errorf("failed to get graph: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get graph: status %d", resp.StatusCode)
+		return "", newAPIError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return string(body), nil
+}
+
+// GraphPageURL returns the URL of the HTML detail page of a graph. mode is
+// empty, "simple" or "simple-short".
+func (c *Client) GraphPageURL(username, graphID, mode string) string {
+	pageURL := fmt.Sprintf("%s/v1/users/%s/graphs/%s.html", c.BaseURL, username, graphID)
+	if mode != "" {
+		pageURL += "?" + url.Values{"mode": {mode}}.Encode()
+	}
+	return pageURL
+}
+
+// ProfilePageURL returns the URL of the public profile page of a user.
+func (c *Client) ProfilePageURL(username string) string {
+	return fmt.Sprintf("%s/@%s", c.BaseURL, username)
+}
+
+func (c *Client) GetGraphPage(username, graphID, mode string) (string, error) {
+	return c.GetGraphPageContext(context.Background(), username, graphID, mode)
+}
+
+// GetGraphPageContext fetches the HTML detail page of a graph.
+func (c *Client) GetGraphPageContext(ctx context.Context, username, graphID, mode string) (string, error) {
+	return c.getPage(ctx, c.GraphPageURL(username, graphID, mode), "graph page")
+}
+
+func (c *Client) GetProfilePage(username string) (string, error) {
+	return c.GetProfilePageContext(context.Background(), username)
+}
+
+// GetProfilePageContext fetches the public profile page of a user.
+func (c *Client) GetProfilePageContext(ctx context.Context, username string) (string, error) {
+	return c.getPage(ctx, c.ProfilePageURL(username), "profile page")
+}
+
+func (c *Client) getPage(ctx context.Context, pageURL, what string) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -842,14 +1166,18 @@ func (c *Client) GetGraph(username, graphID string) (string, error) {
 }
 
 func (c *Client) InvokeWebhook(username, webhookHash string) (*PixelaResponse, error) {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v1/users/%s/webhooks/%s", c.BaseURL, username, webhookHash), nil)
+	return c.InvokeWebhookContext(context.Background(), username, webhookHash)
+}
+
+func (c *Client) InvokeWebhookContext(ctx context.Context, username, webhookHash string) (*PixelaResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1/users/%s/webhooks/%s", c.BaseURL, username, webhookHash), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Length", "0")
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to invoke webhook: %w", err)
 	}
@@ -859,7 +1187,12 @@ func (c *Client) InvokeWebhook(username, webhookHash string) (*PixelaResponse, e
 }
 
 func (c *Client) DeleteWebhook(username, token, webhookHash string) (*PixelaResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.DeleteWebhookContext(context.Background(), username, token, webhookHash)
+}
+
+func (c *Client) DeleteWebhookContext(ctx context.Context, username, token, webhookHash string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"DELETE",
 		fmt.Sprintf("%s/v1/users/%s/webhooks/%s", c.BaseURL, username, webhookHash),
 		nil,
@@ -870,7 +1203,7 @@ func (c *Client) DeleteWebhook(username, token, webhookHash string) (*PixelaResp
 
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete webhook: %w", err)
 	}
@@ -879,14 +1212,269 @@ func (c *Client) DeleteWebhook(username, token, webhookHash string) (*PixelaResp
 	return c.parseResponse(resp)
 }
 
+func (c *Client) CreateChannel(username, token string, req CreateChannelRequest) (*PixelaResponse, error) {
+	return c.CreateChannelContext(context.Background(), username, token, req)
+}
+
+func (c *Client) CreateChannelContext(ctx context.Context, username, token string, req CreateChannelRequest) (*PixelaResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		fmt.Sprintf("%s/v1/users/%s/channels", c.BaseURL, username),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create channel: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return c.parseResponse(resp)
+}
+
+func (c *Client) GetChannels(username, token string) (*GetChannelsResponse, error) {
+	return c.GetChannelsContext(context.Background(), username, token)
+}
+
+func (c *Client) GetChannelsContext(ctx context.Context, username, token string) (*GetChannelsResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/v1/users/%s/channels", c.BaseURL, username),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channels: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var channelsResponse GetChannelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&channelsResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &channelsResponse, nil
+}
+
+func (c *Client) UpdateChannel(username, token, channelID string, req UpdateChannelRequest) (*PixelaResponse, error) {
+	return c.UpdateChannelContext(context.Background(), username, token, channelID, req)
+}
+
+func (c *Client) UpdateChannelContext(ctx context.Context, username, token, channelID string, req UpdateChannelRequest) (*PixelaResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"PUT",
+		fmt.Sprintf("%s/v1/users/%s/channels/%s", c.BaseURL, username, channelID),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update channel: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return c.parseResponse(resp)
+}
+
+func (c *Client) DeleteChannel(username, token, channelID string) (*PixelaResponse, error) {
+	return c.DeleteChannelContext(context.Background(), username, token, channelID)
+}
+
+func (c *Client) DeleteChannelContext(ctx context.Context, username, token, channelID string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"DELETE",
+		fmt.Sprintf("%s/v1/users/%s/channels/%s", c.BaseURL, username, channelID),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete channel: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return c.parseResponse(resp)
+}
+
+func (c *Client) CreateNotification(username, token, graphID string, req CreateNotificationRequest) (*PixelaResponse, error) {
+	return c.CreateNotificationContext(context.Background(), username, token, graphID, req)
+}
+
+func (c *Client) CreateNotificationContext(ctx context.Context, username, token, graphID string, req CreateNotificationRequest) (*PixelaResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		fmt.Sprintf("%s/v1/users/%s/graphs/%s/notifications", c.BaseURL, username, graphID),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return c.parseResponse(resp)
+}
+
+func (c *Client) GetNotifications(username, token, graphID string) (*GetNotificationsResponse, error) {
+	return c.GetNotificationsContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) GetNotificationsContext(ctx context.Context, username, token, graphID string) (*GetNotificationsResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/v1/users/%s/graphs/%s/notifications", c.BaseURL, username, graphID),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var notificationsResponse GetNotificationsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&notificationsResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &notificationsResponse, nil
+}
+
+func (c *Client) UpdateNotification(username, token, graphID, notificationID string, req UpdateNotificationRequest) (*PixelaResponse, error) {
+	return c.UpdateNotificationContext(context.Background(), username, token, graphID, notificationID, req)
+}
+
+func (c *Client) UpdateNotificationContext(ctx context.Context, username, token, graphID, notificationID string, req UpdateNotificationRequest) (*PixelaResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"PUT",
+		fmt.Sprintf("%s/v1/users/%s/graphs/%s/notifications/%s", c.BaseURL, username, graphID, notificationID),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return c.parseResponse(resp)
+}
+
+func (c *Client) DeleteNotification(username, token, graphID, notificationID string) (*PixelaResponse, error) {
+	return c.DeleteNotificationContext(context.Background(), username, token, graphID, notificationID)
+}
+
+func (c *Client) DeleteNotificationContext(ctx context.Context, username, token, graphID, notificationID string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"DELETE",
+		fmt.Sprintf("%s/v1/users/%s/graphs/%s/notifications/%s", c.BaseURL, username, graphID, notificationID),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-USER-TOKEN", token)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return c.parseResponse(resp)
+}
+
 func (c *Client) AddPixel(username, token, graphID, quantity string) (*PixelaResponse, error) {
+	return c.AddPixelContext(context.Background(), username, token, graphID, quantity)
+}
+
+func (c *Client) AddPixelContext(ctx context.Context, username, token, graphID, quantity string) (*PixelaResponse, error) {
 	reqBody := map[string]string{"quantity": quantity}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/add", c.BaseURL, username, graphID),
 		bytes.NewBuffer(jsonData),
@@ -898,7 +1486,7 @@ func (c *Client) AddPixel(username, token, graphID, quantity string) (*PixelaRes
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add pixel: %w", err)
 	}
@@ -908,13 +1496,18 @@ func (c *Client) AddPixel(username, token, graphID, quantity string) (*PixelaRes
 }
 
 func (c *Client) SubtractPixel(username, token, graphID, quantity string) (*PixelaResponse, error) {
+	return c.SubtractPixelContext(context.Background(), username, token, graphID, quantity)
+}
+
+func (c *Client) SubtractPixelContext(ctx context.Context, username, token, graphID, quantity string) (*PixelaResponse, error) {
 	reqBody := map[string]string{"quantity": quantity}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"PUT",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/subtract", c.BaseURL, username, graphID),
 		bytes.NewBuffer(jsonData),
@@ -926,7 +1519,7 @@ func (c *Client) SubtractPixel(username, token, graphID, quantity string) (*Pixe
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-USER-TOKEN", token)
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to subtract pixel: %w", err)
 	}
@@ -936,7 +1529,12 @@ func (c *Client) SubtractPixel(username, token, graphID, quantity string) (*Pixe
 }
 
 func (c *Client) Stopwatch(username, token, graphID string) (*PixelaResponse, error) {
-	httpReq, err := http.NewRequest(
+	return c.StopwatchContext(context.Background(), username, token, graphID)
+}
+
+func (c *Client) StopwatchContext(ctx context.Context, username, token, graphID string) (*PixelaResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		fmt.Sprintf("%s/v1/users/%s/graphs/%s/stopwatch", c.BaseURL, username, graphID),
 		nil,
@@ -948,7 +1546,7 @@ func (c *Client) Stopwatch(username, token, graphID string) (*PixelaResponse, er
 	httpReq.Header.Set("X-USER-TOKEN", token)
 	httpReq.Header.Set("Content-Length", "0")
 
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call stopwatch: %w", err)
 	}
@@ -957,16 +1555,26 @@ func (c *Client) Stopwatch(username, token, graphID string) (*PixelaResponse, er
 	return c.parseResponse(resp)
 }
 
+// parseResponse decodes Pixela's standard response and turns failures into
+// an *APIError.
 func (c *Client) parseResponse(resp *http.Response) (*PixelaResponse, error) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIErrorFromBody(resp, body)
+	}
+
 	var pixelaResp PixelaResponse
 	if err := json.Unmarshal(body, &pixelaResp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
+	if !pixelaResp.IsSuccess {
+		return nil, newAPIErrorFromBody(resp, body)
+	}
+
 	return &pixelaResp, nil
 }
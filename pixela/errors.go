@@ -0,0 +1,92 @@
+package pixela
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIError is returned by every Client method when Pixela answers with a
+// non-success status or `"isSuccess": false`.
+type APIError struct {
+	StatusCode int
+	// Message is Pixela's `message` field, or the HTTP status text when the
+	// body carried none.
+	Message string
+	// IsRejected is set when Pixela rejected the request for a
+	// non-supporter account; such requests were not processed.
+	IsRejected bool
+	Method     string
+	Endpoint   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d, %s %s)", e.Message, e.StatusCode, e.Method, e.Endpoint)
+}
+
+// Retryable reports whether repeating the request may succeed.
+func (e *APIError) Retryable() bool {
+	return e.IsRejected || retryableStatus(e.StatusCode)
+}
+
+// newAPIError builds an APIError from a failed response, consuming its body.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return newAPIErrorFromBody(resp, body)
+}
+
+func newAPIErrorFromBody(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     resp.Request.Method,
+		Endpoint:   resp.Request.URL.Path,
+	}
+
+	var pixelaResp PixelaResponse
+	if err := json.Unmarshal(body, &pixelaResp); err == nil {
+		apiErr.Message = pixelaResp.Message
+		apiErr.IsRejected = pixelaResp.IsRejected
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return apiErr
+}
+
+// AsAPIError returns the APIError wrapped in err, if any.
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// IsNotFound reports whether err is a Pixela 404 (unknown user, graph,
+// pixel or webhook).
+func IsNotFound(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsUnauthorized reports whether Pixela refused the credentials.
+func IsUnauthorized(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// IsRateLimited reports whether the request was throttled, either with 429
+// or by Pixela's rejection of requests from non-supporter accounts.
+func IsRateLimited(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.IsRejected)
+}
+
+// IsRetryable reports whether repeating the request may succeed.
+func IsRetryable(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.Retryable()
+}
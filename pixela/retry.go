@@ -0,0 +1,276 @@
+package pixela
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how the client retries requests.
+//
+// Requests that Pixela rejected (`"isRejected": true`, sent to non-supporter
+// accounts) were not processed and are retried regardless of method. Other
+// transient failures (429, 502, 503, 504 and network errors) are retried only
+// for idempotent methods. A Retry-After header overrides the backoff delay,
+// capped at MaxDelay so a long one cannot stall a call.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry; it doubles on every
+	// further retry, with jitter, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    8 * time.Second,
+	}
+}
+
+// backoff returns the delay before the given retry (1 for the first retry).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Equal jitter: somewhere between half and the full delay
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Attempt describes a single HTTP attempt made for a request.
+type Attempt struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Rejected   bool
+	Err        error
+	// Retried reports whether another attempt followed this one, after Delay.
+	Retried bool
+	Delay   time.Duration
+}
+
+// RetryTrace collects the attempts made for every request issued with a
+// context returned by WithRetryTrace.
+type RetryTrace struct {
+	mu       sync.Mutex
+	attempts []Attempt
+}
+
+type retryTraceKey struct{}
+
+// WithRetryTrace returns a context that records request attempts into the
+// returned trace.
+func WithRetryTrace(ctx context.Context) (context.Context, *RetryTrace) {
+	trace := &RetryTrace{}
+	return context.WithValue(ctx, retryTraceKey{}, trace), trace
+}
+
+type noRetryKey struct{}
+
+// WithoutRetries returns a context whose requests are attempted only once
+// and are not recorded in a RetryTrace, for best-effort lookups.
+func WithoutRetries(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, retryTraceKey{}, (*RetryTrace)(nil))
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+func retryTraceFrom(ctx context.Context) *RetryTrace {
+	trace, _ := ctx.Value(retryTraceKey{}).(*RetryTrace)
+	return trace
+}
+
+func (t *RetryTrace) record(a Attempt) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts = append(t.attempts, a)
+}
+
+func (t *RetryTrace) Attempts() []Attempt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Attempt(nil), t.attempts...)
+}
+
+// Retries returns the number of attempts that were retried.
+func (t *RetryTrace) Retries() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	retries := 0
+	for _, a := range t.attempts {
+		if a.Retried {
+			retries++
+		}
+	}
+	return retries
+}
+
+// Summary describes the attempts in one line, e.g.
+// "3 attempts: 503 (rejected), 503 (rejected), 200".
+func (t *RetryTrace) Summary() string {
+	attempts := t.Attempts()
+	parts := make([]string, 0, len(attempts))
+	for _, a := range attempts {
+		switch {
+		case a.Err != nil:
+			parts = append(parts, "network error")
+		case a.Rejected:
+			parts = append(parts, fmt.Sprintf("%d (rejected)", a.StatusCode))
+		default:
+			parts = append(parts, strconv.Itoa(a.StatusCode))
+		}
+	}
+	return fmt.Sprintf("%d attempts: %s", len(attempts), strings.Join(parts, ", "))
+}
+
+// do sends req, retrying according to c.Retry. The request body must be
+// replayable (requests built with a bytes.Buffer body are).
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	trace := retryTraceFrom(ctx)
+	maxAttempts := c.Retry.MaxAttempts
+	if ctx.Value(noRetryKey{}) != nil {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := c.HTTPClient.Do(attemptReq)
+		record := Attempt{Method: req.Method, Path: req.URL.Path, Err: err}
+		lastAttempt := attempt >= maxAttempts
+
+		if err != nil {
+			if lastAttempt || ctx.Err() != nil || !isIdempotent(req.Method) {
+				trace.record(record)
+				return nil, err
+			}
+			record.Retried = true
+			record.Delay = c.Retry.backoff(attempt)
+			trace.record(record)
+			if err := sleep(ctx, record.Delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		record.StatusCode = resp.StatusCode
+		if resp.StatusCode < 400 {
+			trace.record(record)
+			return resp, nil
+		}
+
+		// Peek at the body to detect Pixela's rejection, then restore it
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if readErr != nil {
+			trace.record(record)
+			return resp, nil
+		}
+
+		var pixelaResp struct {
+			IsRejected bool `json:"isRejected"`
+		}
+		_ = json.Unmarshal(body, &pixelaResp)
+		record.Rejected = pixelaResp.IsRejected
+
+		if lastAttempt || !shouldRetry(req.Method, resp.StatusCode, pixelaResp.IsRejected) {
+			trace.record(record)
+			return resp, nil
+		}
+
+		record.Retried = true
+		record.Delay = c.Retry.backoff(attempt)
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			record.Delay = min(after, c.Retry.MaxDelay)
+		}
+		trace.record(record)
+		if err := sleep(ctx, record.Delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func shouldRetry(method string, status int, rejected bool) bool {
+	if rejected {
+		return true
+	}
+	return retryableStatus(status) && isIdempotent(method)
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isIdempotent reports whether repeating a request with this method is safe.
+// PUT is left out: Pixela's /increment, /decrement, /add and /subtract
+// endpoints use it without being idempotent.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,177 @@
+package pixela
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a local stand-in of Pixela that retries
+// quickly.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Client{
+		BaseURL:    server.URL,
+		HTTPClient: server.Client(),
+		Retry: RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+		},
+	}
+}
+
+func TestDoRetriesRejectedPost(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"date":"20240101","quantity":"5"}` {
+			t.Errorf("attempt %d body = %s", calls.Load()+1, body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"message":"Please retry this request.","isSuccess":false,"isRejected":true}`)
+			return
+		}
+		io.WriteString(w, `{"message":"Success.","isSuccess":true}`)
+	})
+
+	ctx, trace := WithRetryTrace(context.Background())
+	if _, err := client.PostPixelContext(ctx, "alice", "secret", "g1", PostPixelRequest{Date: "20240101", Quantity: "5"}); err != nil {
+		t.Fatalf("PostPixelContext: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+	if got := trace.Retries(); got != 2 {
+		t.Errorf("trace retries = %d, want 2", got)
+	}
+	if got, want := trace.Summary(), "3 attempts: 503 (rejected), 503 (rejected), 200"; got != want {
+		t.Errorf("trace summary = %q, want %q", got, want)
+	}
+}
+
+func TestDoDoesNotRetryUnrejectedPost(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `{"message":"Service unavailable.","isSuccess":false}`)
+	})
+
+	_, err := client.PostPixelContext(context.Background(), "alice", "secret", "g1", PostPixelRequest{Date: "20240101", Quantity: "5"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want a 503 *APIError", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
+
+func TestDoRetriesGetNetworkErrors(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		io.WriteString(w, `{"graphs":[]}`)
+	})
+
+	ctx, trace := WithRetryTrace(context.Background())
+	if _, err := client.GetGraphsContext(ctx, "alice", "secret"); err != nil {
+		t.Fatalf("GetGraphsContext: %v", err)
+	}
+	if got, want := trace.Summary(), "3 attempts: network error, network error, 200"; got != want {
+		t.Errorf("trace summary = %q, want %q", got, want)
+	}
+}
+
+func TestDoHonoursRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{"seconds", "1", time.Second},
+		{"capped at MaxDelay", "3600", 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				io.WriteString(w, `{"graphs":[]}`)
+			})
+			client.Retry.MaxDelay = 2 * time.Second
+
+			ctx, trace := WithRetryTrace(context.Background())
+			start := time.Now()
+			if _, err := client.GetGraphsContext(ctx, "alice", "secret"); err != nil {
+				t.Fatalf("GetGraphsContext: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed < tt.want {
+				t.Errorf("returned after %v, want at least %v", elapsed, tt.want)
+			}
+			attempts := trace.Attempts()
+			if len(attempts) != 2 || attempts[0].Delay != tt.want {
+				t.Errorf("attempts = %+v, want a retry after %v", attempts, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoStopsBackoffOnCancel(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client.Retry.BaseDelay = time.Hour
+	client.Retry.MaxDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetGraphsContext(ctx, "alice", "secret")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %v, want the backoff to stop on cancel", elapsed)
+	}
+}
+
+func TestWithoutRetriesMakesOneUntracedAttempt(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	ctx, trace := WithRetryTrace(context.Background())
+	if _, err := client.GetGraphsContext(WithoutRetries(ctx), "alice", "secret"); err == nil {
+		t.Fatal("GetGraphsContext succeeded, want a 503 error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	if got := len(trace.Attempts()); got != 0 {
+		t.Errorf("trace recorded %d attempt(s), want none", got)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy restricts the tools a client may use. The server policy decides
+// which tools are listed and callable at all; a profile's policy further
+// restricts the calls made with that profile.
+type Policy struct {
+	// ReadOnly only allows tools that do not change anything on Pixela.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// AllowTools, when not empty, names the only tools allowed.
+	AllowTools []string `json:"allowTools,omitempty"`
+	// DenyTools names tools that are never allowed.
+	DenyTools []string `json:"denyTools,omitempty"`
+}
+
+// Allows reports whether the policy permits calling tool.
+func (p Policy) Allows(tool *Tool) bool {
+	if p.ReadOnly && !tool.ReadOnly() {
+		return false
+	}
+	if len(p.AllowTools) > 0 && !containsString(p.AllowTools, tool.Name) {
+		return false
+	}
+	return !containsString(p.DenyTools, tool.Name)
+}
+
+// Check reports tool names in the allow and deny lists that do not exist,
+// which are most likely typos.
+func (p Policy) Check(tools *ToolRegistry) error {
+	for _, name := range append(append([]string(nil), p.AllowTools...), p.DenyTools...) {
+		if _, ok := tools.Lookup(name); !ok {
+			return fmt.Errorf("unknown tool %q in tool policy", name)
+		}
+	}
+	return nil
+}
+
+// splitToolList parses a comma-separated list of tool names given as a flag.
+func splitToolList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func toolNotAllowedError(tool *Tool, by string) *MCPError {
+	return &MCPError{
+		Code:    errCodeInvalidParams,
+		Message: fmt.Sprintf("Tool %s is not allowed by %s", tool.Name, by),
+	}
+}
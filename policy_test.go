@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServerPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		listed  []string
+		blocked []string
+	}{
+		{
+			name:    "read-only",
+			policy:  Policy{ReadOnly: true},
+			listed:  []string{"get_graphs", "get_graph_svg", "list_profiles"},
+			blocked: []string{"post_pixel", "update_graph", "delete_graph", "stopwatch"},
+		},
+		{
+			name:    "allow list",
+			policy:  Policy{AllowTools: []string{"get_graphs", "post_pixel"}},
+			listed:  []string{"get_graphs", "post_pixel"},
+			blocked: []string{"get_pixels", "delete_graph", "list_profiles"},
+		},
+		{
+			name:    "deny list",
+			policy:  Policy{DenyTools: []string{"delete_user", "delete_graph"}},
+			listed:  []string{"get_graphs", "post_pixel", "delete_pixel"},
+			blocked: []string{"delete_user", "delete_graph"},
+		},
+		{
+			name:    "deny wins over allow",
+			policy:  Policy{AllowTools: []string{"get_graphs", "delete_graph"}, DenyTools: []string{"delete_graph"}},
+			listed:  []string{"get_graphs"},
+			blocked: []string{"delete_graph"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Policy = tt.policy
+			session := newTestSession(t, config)
+			session.initialize("2025-06-18", nil)
+
+			tools := listTools(session, 1)
+			for _, name := range tt.listed {
+				if _, ok := tools[name]; !ok {
+					t.Errorf("%s is not listed", name)
+				}
+			}
+			for i, name := range tt.blocked {
+				if _, ok := tools[name]; ok {
+					t.Errorf("%s is listed", name)
+				}
+
+				session.request(i+2, "tools/call", map[string]interface{}{"name": name, "arguments": map[string]interface{}{}})
+				response := session.receive()
+				message, _ := response["error"].(map[string]interface{})["message"].(string)
+				if errorCode(response) != errCodeInvalidParams || !strings.Contains(message, "not allowed by the server policy") {
+					t.Errorf("calling %s = %v, want it rejected by the server policy", name, response)
+				}
+			}
+		})
+	}
+}
+
+func TestProfilePolicy(t *testing.T) {
+	baseURL := newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"graphs":[]}`))
+	})
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	writeProfiles(t, path, map[string]Profile{
+		"viewer": {Username: "alice", Token: "secret-token", BaseURL: baseURL, Policy: Policy{ReadOnly: true}},
+		"poster": {Username: "alice", Token: "secret-token", BaseURL: baseURL, Policy: Policy{AllowTools: []string{"post_pixel"}}},
+		"admin":  {Username: "alice", Token: "secret-token", BaseURL: baseURL},
+	})
+	config := newTestConfig()
+	config.Policy = Policy{DenyTools: []string{"delete_user"}}
+	var err error
+	if config.Profiles, err = LoadProfiles(path, true); err != nil {
+		t.Fatal(err)
+	}
+	session := newTestSession(t, config)
+	session.initialize("2025-06-18", nil)
+
+	// Profile policies restrict calls, not the list shared by all profiles
+	tools := listTools(session, 1)
+	if _, ok := tools["post_pixel"]; !ok {
+		t.Error("post_pixel is not listed")
+	}
+
+	tests := []struct {
+		profile string
+		tool    string
+		blocked string
+	}{
+		{"viewer", "get_graphs", ""},
+		{"viewer", "post_pixel", `the policy of profile "viewer"`},
+		{"poster", "get_graphs", `the policy of profile "poster"`},
+		{"admin", "get_graphs", ""},
+		{"admin", "delete_user", "the server policy"},
+	}
+	for i, tt := range tests {
+		session.request(i+2, "tools/call", map[string]interface{}{"name": tt.tool, "arguments": map[string]interface{}{"profile": tt.profile}})
+		response := session.receive()
+		if tt.blocked == "" {
+			if response["result"] == nil {
+				t.Errorf("%s with profile %s = %v, want a result", tt.tool, tt.profile, response)
+			}
+			continue
+		}
+		message, _ := response["error"].(map[string]interface{})["message"].(string)
+		if errorCode(response) != errCodeInvalidParams || !strings.Contains(message, tt.blocked) {
+			t.Errorf("%s with profile %s = %v, want it rejected by %s", tt.tool, tt.profile, response, tt.blocked)
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	tools := newToolRegistry()
+	if err := (Policy{AllowTools: []string{"get_graphs"}, DenyTools: []string{"delete_user"}}).Check(tools); err != nil {
+		t.Errorf("Check of known tools: %v", err)
+	}
+	if err := (Policy{DenyTools: []string{"delete_users"}}).Check(tools); err == nil || !strings.Contains(err.Error(), "delete_users") {
+		t.Errorf("Check of a misspelled tool = %v, want an error naming it", err)
+	}
+}
+
+func TestSplitToolList(t *testing.T) {
+	got := splitToolList(" get_graphs,, post_pixel ,")
+	if want := []string{"get_graphs", "post_pixel"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitToolList = %v, want %v", got, want)
+	}
+}
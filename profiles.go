@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// profileReloadInterval is how often the profiles file is checked for
+// changes. Tests shorten it.
+var profileReloadInterval = 2 * time.Second
+
+// Profile is a named set of credentials for one Pixela user.
+type Profile struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+	// BaseURL overrides the Pixela API base URL, e.g. for a staging server.
+	BaseURL string `json:"baseURL,omitempty"`
+	// Policy restricts the tools that may be called with this profile, on
+	// top of the server policy.
+	Policy
+}
+
+// ProfileStore holds the profiles read from a JSON file mapping profile
+// names to profiles, and reloads them when the file changes.
+type ProfileStore struct {
+	path string
+
+	mu       sync.RWMutex
+	profiles map[string]Profile
+	modTime  time.Time
+	size     int64
+
+	// subscribers are called after every reload that changes the profiles.
+	subscribers    map[int]func()
+	nextSubscriber int
+}
+
+func newProfileStore(path string) *ProfileStore {
+	return &ProfileStore{path: path, profiles: map[string]Profile{}, subscribers: map[int]func(){}}
+}
+
+// defaultProfilesPath returns the profiles file used when neither -profiles
+// nor PIXELA_MCP_PROFILES is given.
+func defaultProfilesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pixela-mcp", "profiles.json")
+}
+
+// LoadProfiles reads the profiles file at path. As with LoadConfig, a
+// missing file is only an error when required is set; the store then starts
+// empty and picks the file up once it is created.
+func LoadProfiles(path string, required bool) (*ProfileStore, error) {
+	store := newProfileStore(path)
+	if path == "" {
+		return store, nil
+	}
+
+	if err := store.reload(); err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			return store, nil
+		}
+		return nil, err
+	}
+	return store, nil
+}
+
+func (p *ProfileStore) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+
+	var profiles map[string]Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("failed to parse profiles file %s: %w", p.path, err)
+	}
+	for name, profile := range profiles {
+		if profile.Username == "" || profile.Token == "" {
+			return fmt.Errorf("profile %q in %s needs both username and token", name, p.path)
+		}
+		profile.BaseURL = strings.TrimSuffix(profile.BaseURL, "/")
+		profiles[name] = profile
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.profiles = profiles
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	return nil
+}
+
+// Watch reloads the profiles whenever the file's modification time or size
+// changes, until ctx is cancelled. A file that fails to parse is reported
+// and the previous profiles are kept; a removed file clears them.
+func (p *ProfileStore) Watch(ctx context.Context) {
+	if p.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(profileReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(p.path)
+		if err != nil {
+			p.mu.Lock()
+			cleared := len(p.profiles) > 0
+			p.profiles = map[string]Profile{}
+			p.modTime, p.size = time.Time{}, 0
+			p.mu.Unlock()
+			if cleared {
+				log.Printf("Profiles file %s is gone, profiles cleared", p.path)
+				p.notify()
+			}
+			continue
+		}
+
+		p.mu.RLock()
+		changed := !info.ModTime().Equal(p.modTime) || info.Size() != p.size
+		p.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := p.reload(); err != nil {
+			log.Printf("Keeping previous profiles: %v", err)
+			// Do not retry until the file changes again
+			p.mu.Lock()
+			p.modTime, p.size = info.ModTime(), info.Size()
+			p.mu.Unlock()
+			continue
+		}
+		log.Printf("Reloaded %d profile(s) from %s", p.Len(), p.path)
+		p.notify()
+	}
+}
+
+// Subscribe calls onChange after every reload that changes the profiles,
+// until ctx is cancelled.
+func (p *ProfileStore) Subscribe(ctx context.Context, onChange func()) {
+	p.mu.Lock()
+	id := p.nextSubscriber
+	p.nextSubscriber++
+	p.subscribers[id] = onChange
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		delete(p.subscribers, id)
+		p.mu.Unlock()
+	}()
+}
+
+func (p *ProfileStore) notify() {
+	p.mu.RLock()
+	subscribers := make([]func(), 0, len(p.subscribers))
+	for _, onChange := range p.subscribers {
+		subscribers = append(subscribers, onChange)
+	}
+	p.mu.RUnlock()
+
+	for _, onChange := range subscribers {
+		onChange()
+	}
+}
+
+func (p *ProfileStore) Lookup(name string) (Profile, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	profile, ok := p.profiles[name]
+	return profile, ok
+}
+
+// Names returns the profile names in sorted order.
+func (p *ProfileStore) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, 0, len(p.profiles))
+	for name := range p.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *ProfileStore) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.profiles)
+}
+
+// Tokens returns the tokens of all profiles.
+func (p *ProfileStore) Tokens() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	tokens := make([]string, 0, len(p.profiles))
+	for _, profile := range p.profiles {
+		tokens = append(tokens, profile.Token)
+	}
+	return tokens
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadProfilesRejectsIncompleteProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	writeProfiles(t, path, map[string]Profile{"bot": {Username: "bot"}})
+
+	if _, err := LoadProfiles(path, true); err == nil || !strings.Contains(err.Error(), "needs both username and token") {
+		t.Errorf("LoadProfiles = %v, want an error about the missing token", err)
+	}
+}
+
+func TestProfileArgument(t *testing.T) {
+	var gotPath, gotToken string
+	baseURL := newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotToken = r.URL.Path, r.Header.Get("X-USER-TOKEN")
+		w.Write([]byte(`{"graphs":[]}`))
+	})
+	session := newTestSession(t, newProfileConfig(t, baseURL))
+	session.initialize("2025-06-18", nil)
+
+	if result := session.call(1, "get_graphs", map[string]interface{}{"profile": "test"}); result["isError"] == true {
+		t.Fatalf("get_graphs with a profile = %v, want success", result)
+	}
+	if gotPath != "/v1/users/alice/graphs" || gotToken != "secret-token" {
+		t.Errorf("Pixela got %s with token %q, want the profile's user and token", gotPath, gotToken)
+	}
+
+	session.request(2, "tools/call", map[string]interface{}{"name": "get_graphs", "arguments": map[string]interface{}{"profile": "nobody"}})
+	if response := session.receive(); errorCode(response) != errCodeInvalidParams {
+		t.Errorf("unknown profile = %v, want error %d", response, errCodeInvalidParams)
+	}
+
+	// The profile's token is not sent on behalf of another user
+	session.request(3, "tools/call", map[string]interface{}{"name": "get_graphs", "arguments": map[string]interface{}{"profile": "test", "username": "bob"}})
+	if response := session.receive(); errorCode(response) != errCodeInvalidParams {
+		t.Errorf("profile with another username = %v, want error %d", response, errCodeInvalidParams)
+	}
+}
+
+// profileNames returns the names listed by list_profiles.
+func profileNames(t *testing.T, session *testSession, id int) []string {
+	t.Helper()
+	result := session.call(id, "list_profiles", map[string]interface{}{})
+	output, _ := result["structuredContent"].(map[string]interface{})
+	profiles, _ := output["profiles"].([]interface{})
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.(map[string]interface{})["name"].(string))
+	}
+	return names
+}
+
+func TestProfileHotReload(t *testing.T) {
+	interval := profileReloadInterval
+	profileReloadInterval = 10 * time.Millisecond
+	t.Cleanup(func() { profileReloadInterval = interval })
+
+	config := newProfileConfig(t, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		config.Profiles.Watch(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-watched
+	})
+
+	sessions := []*testSession{newTestSession(t, config), newTestSession(t, config)}
+	for _, session := range sessions {
+		session.initialize("2025-06-18", nil)
+		if names := profileNames(t, session, 1); strings.Join(names, ",") != "test" {
+			t.Fatalf("profiles before the reload = %v, want [test]", names)
+		}
+	}
+
+	writeProfiles(t, config.Profiles.path, map[string]Profile{
+		"test": {Username: "alice", Token: "secret-token"},
+		"bot":  {Username: "bot", Token: "bot-secret-token"},
+	})
+
+	for i, session := range sessions {
+		if message := session.receive(); message["method"] != "notifications/tools/list_changed" {
+			t.Fatalf("session %d got %v, want notifications/tools/list_changed", i, message)
+		}
+		if names := profileNames(t, session, 2); strings.Join(names, ",") != "bot,test" {
+			t.Errorf("session %d profiles after the reload = %v, want [bot test]", i, names)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"runtime/debug"
+)
+
+// version is the server version reported in serverInfo. Release builds set
+// it at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.3"
+var version = ""
+
+// serverVersion returns the link-time version, falling back to the module
+// version recorded by `go install` and finally to "dev".
+func serverVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// supportedProtocolVersions lists the MCP revisions the server speaks,
+// newest first.
+var supportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// protocolFeatures are the optional parts of MCP whose availability depends
+// on the negotiated revision.
+type protocolFeatures struct {
+	// StructuredOutput enables structuredContent in tool results and
+	// outputSchema in tools/list (2025-06-18).
+	StructuredOutput bool
+	// ToolAnnotations enables behaviour hints in tools/list (2025-03-26).
+	ToolAnnotations bool
+	// Elicitation enables elicitation/create requests to the client
+	// (2025-06-18); the client must also declare the capability.
+	Elicitation bool
+}
+
+// featuresFor returns the features of a supported revision. Revisions are
+// dates, so they compare lexically.
+func featuresFor(protocolVersion string) protocolFeatures {
+	return protocolFeatures{
+		StructuredOutput: protocolVersion >= "2025-06-18",
+		ToolAnnotations:  protocolVersion >= "2025-03-26",
+		Elicitation:      protocolVersion >= "2025-06-18",
+	}
+}
+
+// negotiateProtocolVersion accepts the client's revision when supported and
+// otherwise proposes the latest one, leaving it to the client to disconnect.
+func negotiateProtocolVersion(requested string) string {
+	if containsString(supportedProtocolVersions, requested) {
+		return requested
+	}
+	return supportedProtocolVersions[0]
+}
+
+// ClientCapabilities is the part of the client's initialize capabilities the
+// server acts on.
+type ClientCapabilities struct {
+	Roots *struct {
+		ListChanged bool `json:"listChanged,omitempty"`
+	} `json:"roots,omitempty"`
+	Sampling    *struct{} `json:"sampling,omitempty"`
+	Elicitation *struct{} `json:"elicitation,omitempty"`
+}
+
+type InitializeParams struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"clientInfo"`
+}
+
+func (s *MCPServer) handleInitialize(params interface{}) map[string]interface{} {
+	var initParams InitializeParams
+	if data, err := json.Marshal(params); err == nil {
+		if err := json.Unmarshal(data, &initParams); err != nil {
+			log.Printf("Ignoring malformed initialize params: %v", err)
+		}
+	}
+
+	protocolVersion := negotiateProtocolVersion(initParams.ProtocolVersion)
+	if protocolVersion != initParams.ProtocolVersion {
+		log.Printf("Client requested protocol version %q, proposing %s", initParams.ProtocolVersion, protocolVersion)
+	}
+	if initParams.ClientInfo.Name != "" {
+		log.Printf("Client: %s %s (protocol %s)", initParams.ClientInfo.Name, initParams.ClientInfo.Version, protocolVersion)
+	}
+
+	s.mu.Lock()
+	s.protocolVersion = protocolVersion
+	s.features = featuresFor(protocolVersion)
+	s.clientCapabilities = initParams.Capabilities
+	s.mu.Unlock()
+
+	return map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities": map[string]interface{}{
+			"tools": map[string]interface{}{
+				"listChanged": true,
+			},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    "pixela-mcp",
+			"version": serverVersion(),
+		},
+	}
+}
+
+func (s *MCPServer) protocolFeatures() protocolFeatures {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.features
+}
+
+// canElicit reports whether elicitation/create may be sent: the negotiated
+// revision must have it and the client must have declared the capability.
+func (s *MCPServer) canElicit() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.features.Elicitation && s.clientCapabilities.Elicitation != nil
+}
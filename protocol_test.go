@@ -0,0 +1,99 @@
+package main
+
+import "testing"
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"2025-06-18", "2025-06-18"},
+		{"2025-03-26", "2025-03-26"},
+		{"2024-11-05", "2024-11-05"},
+		{"2099-01-01", "2025-06-18"},
+		{"2024-01-01", "2025-06-18"},
+		{"", "2025-06-18"},
+	}
+	for _, tt := range tests {
+		if got := negotiateProtocolVersion(tt.requested); got != tt.want {
+			t.Errorf("negotiateProtocolVersion(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+}
+
+// listTools returns the tools/list entries of a session by name.
+func listTools(session *testSession, id int) map[string]map[string]interface{} {
+	session.t.Helper()
+	session.request(id, "tools/list", nil)
+	response := session.receive()
+	result, _ := response["result"].(map[string]interface{})
+	list, ok := result["tools"].([]interface{})
+	if !ok {
+		session.t.Fatalf("tools/list response = %v, want the tools", response)
+	}
+
+	tools := make(map[string]map[string]interface{}, len(list))
+	for _, entry := range list {
+		tool := entry.(map[string]interface{})
+		tools[tool["name"].(string)] = tool
+	}
+	return tools
+}
+
+// requiresProperty reports whether a tool's input schema requires name.
+func requiresProperty(tool map[string]interface{}, name string) bool {
+	schema, _ := tool["inputSchema"].(map[string]interface{})
+	required, _ := schema["required"].([]interface{})
+	for _, r := range required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProtocolFeatures(t *testing.T) {
+	elicitation := map[string]interface{}{"elicitation": map[string]interface{}{}}
+	tests := []struct {
+		name            string
+		requested       string
+		capabilities    map[string]interface{}
+		negotiated      string
+		annotations     bool
+		structured      bool
+		confirmRequired bool
+	}{
+		{"2025-06-18", "2025-06-18", nil, "2025-06-18", true, true, true},
+		{"2025-06-18 with elicitation", "2025-06-18", elicitation, "2025-06-18", true, true, false},
+		{"2025-03-26 with elicitation", "2025-03-26", elicitation, "2025-03-26", true, false, true},
+		{"2024-11-05 with elicitation", "2024-11-05", elicitation, "2024-11-05", false, false, true},
+		{"unknown version falls back to the latest", "2099-01-01", elicitation, "2025-06-18", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newTestSession(t, newTestConfig())
+			result := session.initialize(tt.requested, tt.capabilities)
+			if got := result["protocolVersion"]; got != tt.negotiated {
+				t.Errorf("negotiated %v, want %s", got, tt.negotiated)
+			}
+
+			tools := listTools(session, 1)
+			_, hasAnnotations := tools["get_graphs"]["annotations"]
+			if hasAnnotations != tt.annotations {
+				t.Errorf("get_graphs has annotations: %v, want %v", hasAnnotations, tt.annotations)
+			}
+			_, hasOutputSchema := tools["get_graphs"]["outputSchema"]
+			if hasOutputSchema != tt.structured {
+				t.Errorf("get_graphs has outputSchema: %v, want %v", hasOutputSchema, tt.structured)
+			}
+			if got := requiresProperty(tools["delete_graph"], "confirm"); got != tt.confirmRequired {
+				t.Errorf("delete_graph requires confirm: %v, want %v", got, tt.confirmRequired)
+			}
+
+			call := session.call(2, "list_profiles", map[string]interface{}{})
+			if _, got := call["structuredContent"]; got != tt.structured {
+				t.Errorf("list_profiles result has structuredContent: %v, want %v", got, tt.structured)
+			}
+		})
+	}
+}
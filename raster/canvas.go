@@ -0,0 +1,199 @@
+package raster
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// subsamples is the number of scanlines sampled per pixel row for
+// antialiasing. Horizontal coverage is computed exactly.
+const subsamples = 4
+
+// canvas is an RGBA image that polygons are composited onto.
+type canvas struct {
+	img      *image.RGBA
+	coverage []float64
+}
+
+func newCanvas(width, height int) *canvas {
+	return &canvas{
+		img:      image.NewRGBA(image.Rect(0, 0, width, height)),
+		coverage: make([]float64, width),
+	}
+}
+
+type edge struct {
+	x0, y0, x1, y1 float64
+	winding        int
+}
+
+type crossing struct {
+	x       float64
+	winding int
+}
+
+// fill composites the polygons, closed implicitly, with color using the
+// nonzero or even-odd rule.
+func (c *canvas) fill(polygons [][]point, color rgba, evenOdd bool) {
+	var edges []edge
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for i, p := range polygon {
+			q := polygon[(i+1)%len(polygon)]
+			minX, maxX = math.Min(minX, p.x), math.Max(maxX, p.x)
+			minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+			switch {
+			case p.y < q.y:
+				edges = append(edges, edge{p.x, p.y, q.x, q.y, 1})
+			case p.y > q.y:
+				edges = append(edges, edge{q.x, q.y, p.x, p.y, -1})
+			}
+		}
+	}
+	if len(edges) == 0 || math.IsNaN(minX+minY+maxX+maxY) {
+		return
+	}
+
+	bounds := c.img.Bounds()
+	x0 := max(int(math.Floor(minX)), 0)
+	x1 := min(int(math.Ceil(maxX)), bounds.Dx())
+	y0 := max(int(math.Floor(minY)), 0)
+	y1 := min(int(math.Ceil(maxY)), bounds.Dy())
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+
+	var crossings []crossing
+	for y := y0; y < y1; y++ {
+		for s := 0; s < subsamples; s++ {
+			sy := float64(y) + (float64(s)+0.5)/subsamples
+			crossings = crossings[:0]
+			for _, e := range edges {
+				if sy >= e.y0 && sy < e.y1 {
+					crossings = append(crossings, crossing{e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), e.winding})
+				}
+			}
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+
+			winding := 0
+			for i, cr := range crossings {
+				winding += cr.winding
+				inside := winding != 0
+				if evenOdd {
+					inside = winding%2 != 0
+				}
+				if inside && i+1 < len(crossings) {
+					c.cover(cr.x, crossings[i+1].x, 1.0/subsamples)
+				}
+			}
+		}
+		c.composite(y, x0, x1, color)
+	}
+}
+
+// cover adds the coverage of the span [from, to) on one subsample
+// scanline.
+func (c *canvas) cover(from, to, weight float64) {
+	from = math.Max(from, 0)
+	to = math.Min(to, float64(len(c.coverage)))
+	for x := int(from); x < len(c.coverage) && float64(x) < to; x++ {
+		if overlap := math.Min(to, float64(x+1)) - math.Max(from, float64(x)); overlap > 0 {
+			c.coverage[x] += overlap * weight
+		}
+	}
+}
+
+// composite blends color over row y by the accumulated coverage and resets
+// it.
+func (c *canvas) composite(y, x0, x1 int, color rgba) {
+	for x := x0; x < x1; x++ {
+		coverage := math.Min(c.coverage[x], 1)
+		c.coverage[x] = 0
+		if coverage <= 0 {
+			continue
+		}
+
+		a := color.a * coverage
+		i := c.img.PixOffset(x, y)
+		pix := c.img.Pix[i : i+4 : i+4]
+		pix[0] = blend(color.r*a, pix[0], a)
+		pix[1] = blend(color.g*a, pix[1], a)
+		pix[2] = blend(color.b*a, pix[2], a)
+		pix[3] = blend(a, pix[3], a)
+	}
+}
+
+// blend composites a premultiplied source component over dst.
+func blend(src float64, dst uint8, alpha float64) uint8 {
+	return uint8(math.Round(src*255 + float64(dst)*(1-alpha)))
+}
+
+// strokePolygons outlines the subpaths with lines of the given width. Each
+// segment becomes a quad and each joint a small disc, all wound the same
+// way so the nonzero rule unites them.
+func strokePolygons(paths []subpath, width float64, lineCap string) [][]point {
+	half := width / 2
+	var polygons [][]point
+	for _, path := range paths {
+		points := path.points
+		if path.closed && len(points) > 1 {
+			points = append(points[:len(points):len(points)], points[0])
+		}
+
+		for i := 0; i+1 < len(points); i++ {
+			p, q := points[i], points[i+1]
+			dx, dy := q.x-p.x, q.y-p.y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			ux, uy := dx/length, dy/length
+			if lineCap == "square" && !path.closed {
+				if i == 0 {
+					p = point{p.x - ux*half, p.y - uy*half}
+				}
+				if i+2 == len(points) {
+					q = point{q.x + ux*half, q.y + uy*half}
+				}
+			}
+			nx, ny := -uy*half, ux*half
+			polygons = append(polygons, positive([]point{
+				{p.x + nx, p.y + ny}, {q.x + nx, q.y + ny}, {q.x - nx, q.y - ny}, {p.x - nx, p.y - ny},
+			}))
+		}
+
+		for i, p := range points {
+			end := !path.closed && (i == 0 || i == len(points)-1)
+			if len(points) > 1 && (!end || lineCap == "round") {
+				polygons = append(polygons, disc(p, half))
+			}
+		}
+	}
+	return polygons
+}
+
+func disc(center point, radius float64) []point {
+	points := make([]point, 0, 16)
+	for i := 0; i < 16; i++ {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / 16)
+		points = append(points, point{center.x + radius*cos, center.y + radius*sin})
+	}
+	return positive(points)
+}
+
+// positive returns polygon wound clockwise in device space.
+func positive(polygon []point) []point {
+	area := 0.0
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p.x*q.y - q.x*p.y
+	}
+	if area < 0 {
+		for i, j := 0, len(polygon)-1; i < j; i, j = i+1, j-1 {
+			polygon[i], polygon[j] = polygon[j], polygon[i]
+		}
+	}
+	return polygon
+}
@@ -0,0 +1,518 @@
+package raster
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// curveSegments is the number of lines a Bézier curve is flattened into.
+const curveSegments = 16
+
+type point struct {
+	x, y float64
+}
+
+// subpath is a flattened subpath. closed is set by Z and decides whether a
+// stroke joins the last point to the first.
+type subpath struct {
+	points []point
+	closed bool
+}
+
+// matrix is an affine transform [a b c d e f] as in SVG.
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func translation(x, y float64) matrix { return matrix{1, 0, 0, 1, x, y} }
+func scaling(x, y float64) matrix     { return matrix{x, 0, 0, y, 0, 0} }
+
+// mul returns the transform that applies n, then m.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m matrix) apply(p point) point {
+	return point{m[0]*p.x + m[2]*p.y + m[4], m[1]*p.x + m[3]*p.y + m[5]}
+}
+
+func (m matrix) applyAll(points []point) []point {
+	out := make([]point, len(points))
+	for i, p := range points {
+		out[i] = m.apply(p)
+	}
+	return out
+}
+
+func (m matrix) det() float64 {
+	return m[0]*m[3] - m[1]*m[2]
+}
+
+var transformPattern = regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
+
+// parseTransform parses a transform list. Unknown or malformed transforms
+// are skipped.
+func parseTransform(s string) matrix {
+	m := identity
+	for _, match := range transformPattern.FindAllStringSubmatch(s, -1) {
+		args := numbers(match[2])
+		switch {
+		case match[1] == "matrix" && len(args) == 6:
+			m = m.mul(matrix{args[0], args[1], args[2], args[3], args[4], args[5]})
+		case match[1] == "translate" && len(args) == 1:
+			m = m.mul(translation(args[0], 0))
+		case match[1] == "translate" && len(args) == 2:
+			m = m.mul(translation(args[0], args[1]))
+		case match[1] == "scale" && len(args) == 1:
+			m = m.mul(scaling(args[0], args[0]))
+		case match[1] == "scale" && len(args) == 2:
+			m = m.mul(scaling(args[0], args[1]))
+		case match[1] == "rotate" && (len(args) == 1 || len(args) == 3):
+			sin, cos := math.Sincos(args[0] * math.Pi / 180)
+			rotation := matrix{cos, sin, -sin, cos, 0, 0}
+			if len(args) == 3 {
+				rotation = translation(args[1], args[2]).mul(rotation).mul(translation(-args[1], -args[2]))
+			}
+			m = m.mul(rotation)
+		case match[1] == "skewX" && len(args) == 1:
+			m = m.mul(matrix{1, 0, math.Tan(args[0] * math.Pi / 180), 1, 0, 0})
+		case match[1] == "skewY" && len(args) == 1:
+			m = m.mul(matrix{1, math.Tan(args[0] * math.Pi / 180), 0, 1, 0, 0})
+		}
+	}
+	return m
+}
+
+// scanner reads the numbers and flags of path data and number lists, which
+// may be separated by whitespace, commas or nothing at all ("1-2.5.5").
+type scanner struct {
+	s string
+	i int
+}
+
+func (sc *scanner) skipSeparators() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+}
+
+// number reads the next number, or reports false without consuming
+// anything if there is none.
+func (sc *scanner) number() (float64, bool) {
+	sc.skipSeparators()
+	start, i := sc.i, sc.i
+	if i < len(sc.s) && (sc.s[i] == '+' || sc.s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for i < len(sc.s) && sc.s[i] >= '0' && sc.s[i] <= '9' {
+		i++
+		digits++
+	}
+	if i < len(sc.s) && sc.s[i] == '.' {
+		i++
+		for i < len(sc.s) && sc.s[i] >= '0' && sc.s[i] <= '9' {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, false
+	}
+	if i < len(sc.s) && (sc.s[i] == 'e' || sc.s[i] == 'E') {
+		j := i + 1
+		if j < len(sc.s) && (sc.s[j] == '+' || sc.s[j] == '-') {
+			j++
+		}
+		if j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+			for j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+				j++
+			}
+			i = j
+		}
+	}
+
+	v, ok := parseNumber(sc.s[start:i])
+	if !ok {
+		return 0, false
+	}
+	sc.i = i
+	return v, true
+}
+
+// flag reads an arc flag, which is a single 0 or 1 that needs no separator.
+func (sc *scanner) flag() (bool, bool) {
+	sc.skipSeparators()
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '0' || sc.s[sc.i] == '1') {
+		sc.i++
+		return sc.s[sc.i-1] == '1', true
+	}
+	return false, false
+}
+
+// peekNumber reports whether a number follows.
+func (sc *scanner) peekNumber() bool {
+	_, ok := (&scanner{s: sc.s, i: sc.i}).number()
+	return ok
+}
+
+func (sc *scanner) numbers(n int) ([]float64, bool) {
+	values := make([]float64, n)
+	for i := range values {
+		v, ok := sc.number()
+		if !ok {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+// numbers parses a list of numbers, stopping at the first malformed one.
+func numbers(s string) []float64 {
+	sc := &scanner{s: s}
+	var values []float64
+	for {
+		v, ok := sc.number()
+		if !ok {
+			return values
+		}
+		values = append(values, v)
+	}
+}
+
+// pathBuilder collects the subpaths of path data.
+type pathBuilder struct {
+	paths       []subpath
+	current     *subpath
+	pos, start  point
+	lastControl point
+	lastCommand byte
+}
+
+func (b *pathBuilder) moveTo(p point) {
+	b.paths = append(b.paths, subpath{points: []point{p}})
+	b.current = &b.paths[len(b.paths)-1]
+	b.pos, b.start = p, p
+}
+
+func (b *pathBuilder) lineTo(p point) {
+	if b.current == nil {
+		// After a Z, drawing continues from the start of the closed subpath
+		b.moveTo(b.pos)
+	}
+	b.current.points = append(b.current.points, p)
+	b.pos = p
+}
+
+func (b *pathBuilder) close() {
+	if b.current != nil {
+		b.current.closed = true
+		b.current = nil
+	}
+	b.pos = b.start
+}
+
+// reflectedControl returns the first control point of a smooth curve: the
+// reflection of the previous control point if the previous command was a
+// curve of the same kind, else the current point.
+func (b *pathBuilder) reflectedControl(kinds string) point {
+	if strings.IndexByte(kinds, b.lastCommand) >= 0 {
+		return point{2*b.pos.x - b.lastControl.x, 2*b.pos.y - b.lastControl.y}
+	}
+	return b.pos
+}
+
+func (b *pathBuilder) cubicTo(c1, c2, end point) {
+	p0 := b.pos
+	for i := 1; i <= curveSegments; i++ {
+		t := float64(i) / curveSegments
+		u := 1 - t
+		b.lineTo(point{
+			u*u*u*p0.x + 3*u*u*t*c1.x + 3*u*t*t*c2.x + t*t*t*end.x,
+			u*u*u*p0.y + 3*u*u*t*c1.y + 3*u*t*t*c2.y + t*t*t*end.y,
+		})
+	}
+	b.lastControl = c2
+}
+
+func (b *pathBuilder) quadTo(c, end point) {
+	p0 := b.pos
+	for i := 1; i <= curveSegments; i++ {
+		t := float64(i) / curveSegments
+		u := 1 - t
+		b.lineTo(point{
+			u*u*p0.x + 2*u*t*c.x + t*t*end.x,
+			u*u*p0.y + 2*u*t*c.y + t*t*end.y,
+		})
+	}
+	b.lastControl = c
+}
+
+// segment reads the arguments of one segment of command and adds it. It
+// reports false if the arguments are malformed.
+func (b *pathBuilder) segment(sc *scanner, command byte, relative, first bool) bool {
+	offset := point{}
+	if relative {
+		offset = b.pos
+	}
+	at := func(x, y float64) point { return point{x + offset.x, y + offset.y} }
+
+	switch command {
+	case 'M':
+		v, ok := sc.numbers(2)
+		if !ok {
+			return false
+		}
+		// Coordinates after the first pair are implicit line commands
+		if first {
+			b.moveTo(at(v[0], v[1]))
+		} else {
+			b.lineTo(at(v[0], v[1]))
+		}
+	case 'L':
+		v, ok := sc.numbers(2)
+		if !ok {
+			return false
+		}
+		b.lineTo(at(v[0], v[1]))
+	case 'H':
+		v, ok := sc.number()
+		if !ok {
+			return false
+		}
+		b.lineTo(point{v + offset.x, b.pos.y})
+	case 'V':
+		v, ok := sc.number()
+		if !ok {
+			return false
+		}
+		b.lineTo(point{b.pos.x, v + offset.y})
+	case 'C':
+		v, ok := sc.numbers(6)
+		if !ok {
+			return false
+		}
+		b.cubicTo(at(v[0], v[1]), at(v[2], v[3]), at(v[4], v[5]))
+	case 'S':
+		v, ok := sc.numbers(4)
+		if !ok {
+			return false
+		}
+		b.cubicTo(b.reflectedControl("CS"), at(v[0], v[1]), at(v[2], v[3]))
+	case 'Q':
+		v, ok := sc.numbers(4)
+		if !ok {
+			return false
+		}
+		b.quadTo(at(v[0], v[1]), at(v[2], v[3]))
+	case 'T':
+		v, ok := sc.numbers(2)
+		if !ok {
+			return false
+		}
+		b.quadTo(b.reflectedControl("QT"), at(v[0], v[1]))
+	case 'A':
+		radii, ok := sc.numbers(3)
+		if !ok {
+			return false
+		}
+		large, ok1 := sc.flag()
+		sweep, ok2 := sc.flag()
+		v, ok3 := sc.numbers(2)
+		if !ok1 || !ok2 || !ok3 {
+			return false
+		}
+		for _, p := range arcPoints(b.pos, radii[0], radii[1], radii[2], large, sweep, at(v[0], v[1])) {
+			b.lineTo(p)
+		}
+	}
+	b.lastCommand = command
+	return true
+}
+
+// parsePath flattens path data into subpaths. As the SVG spec requires,
+// rendering stops at the first error, keeping what was parsed before it.
+func parsePath(d string) []subpath {
+	sc := &scanner{s: d}
+	b := &pathBuilder{}
+
+	for {
+		sc.skipSeparators()
+		if sc.i >= len(sc.s) {
+			return b.paths
+		}
+		command := sc.s[sc.i]
+		if strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", command) < 0 {
+			return b.paths
+		}
+		sc.i++
+		upper := command &^ 0x20
+
+		if upper == 'Z' {
+			b.close()
+			b.lastCommand = 'Z'
+			continue
+		}
+
+		// Further coordinates repeat the command
+		for first := true; first || sc.peekNumber(); first = false {
+			if !b.segment(sc, upper, command != upper, first) {
+				return b.paths
+			}
+		}
+	}
+}
+
+// arcPoints flattens an elliptical arc using the endpoint to center
+// conversion of the SVG spec (appendix B.2.4). The start point is not
+// included.
+func arcPoints(from point, rx, ry, angle float64, large, sweep bool, to point) []point {
+	if from == to {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return []point{to}
+	}
+
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	dx, dy := (from.x-to.x)/2, (from.y-to.y)/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	cx := cos*cx1 - sin*cy1 + (from.x+to.x)/2
+	cy := sin*cx1 + cos*cy1 + (from.y+to.y)/2
+
+	vectorAngle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := vectorAngle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := vectorAngle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// A full turn takes 32 steps; anything else, such as NaN from
+	// coordinates that overflowed, leaves nothing to flatten
+	steps := math.Ceil(math.Abs(delta) / (math.Pi / 16))
+	if !(steps >= 1 && steps <= 32) {
+		return []point{to}
+	}
+	n := int(steps)
+	points := make([]point, 0, n)
+	for i := 1; i <= n; i++ {
+		t := theta + delta*float64(i)/float64(n)
+		st, ct := math.Sincos(t)
+		points = append(points, point{
+			cx + rx*ct*cos - ry*st*sin,
+			cy + rx*ct*sin + ry*st*cos,
+		})
+	}
+	points[len(points)-1] = to
+	return points
+}
+
+// shape returns the geometry of a basic shape or path element, and whether
+// its stroke is closed.
+func (r *renderer) shape(n *node) ([]subpath, bool, bool) {
+	length := func(name string, ref float64) float64 {
+		v, _ := parseLength(n.attrs[name], ref)
+		return v
+	}
+	diagonal := math.Hypot(r.width, r.height) / math.Sqrt2
+
+	switch n.name {
+	case "rect":
+		x, y := length("x", r.width), length("y", r.height)
+		w, h := length("width", r.width), length("height", r.height)
+		if w <= 0 || h <= 0 {
+			return nil, false, false
+		}
+		rx, okX := parseLength(n.attrs["rx"], r.width)
+		ry, okY := parseLength(n.attrs["ry"], r.height)
+		if !okX {
+			rx = ry
+		}
+		if !okY {
+			ry = rx
+		}
+		rx, ry = math.Min(math.Max(rx, 0), w/2), math.Min(math.Max(ry, 0), h/2)
+		if rx == 0 || ry == 0 {
+			return []subpath{{points: []point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}}}, true, true
+		}
+		points := []point{{x + rx, y}}
+		corner := func(from, to point) {
+			points = append(points, arcPoints(from, rx, ry, 0, false, true, to)...)
+		}
+		points = append(points, point{x + w - rx, y})
+		corner(point{x + w - rx, y}, point{x + w, y + ry})
+		points = append(points, point{x + w, y + h - ry})
+		corner(point{x + w, y + h - ry}, point{x + w - rx, y + h})
+		points = append(points, point{x + rx, y + h})
+		corner(point{x + rx, y + h}, point{x, y + h - ry})
+		points = append(points, point{x, y + ry})
+		corner(point{x, y + ry}, point{x + rx, y})
+		return []subpath{{points: points}}, true, true
+	case "circle", "ellipse":
+		cx, cy := length("cx", r.width), length("cy", r.height)
+		var rx, ry float64
+		if n.name == "circle" {
+			rx = length("r", diagonal)
+			ry = rx
+		} else {
+			rx, ry = length("rx", r.width), length("ry", r.height)
+		}
+		if rx <= 0 || ry <= 0 {
+			return nil, false, false
+		}
+		points := make([]point, 0, 64)
+		for i := 0; i < 64; i++ {
+			sin, cos := math.Sincos(2 * math.Pi * float64(i) / 64)
+			points = append(points, point{cx + rx*cos, cy + ry*sin})
+		}
+		return []subpath{{points: points}}, true, true
+	case "line":
+		return []subpath{{points: []point{
+			{length("x1", r.width), length("y1", r.height)},
+			{length("x2", r.width), length("y2", r.height)},
+		}}}, false, true
+	case "polyline", "polygon":
+		values := numbers(n.attrs["points"])
+		points := make([]point, 0, len(values)/2)
+		for i := 0; i+1 < len(values); i += 2 {
+			points = append(points, point{values[i], values[i+1]})
+		}
+		if len(points) < 2 {
+			return nil, false, false
+		}
+		return []subpath{{points: points}}, n.name == "polygon", true
+	case "path":
+		paths := parsePath(n.attrs["d"])
+		return paths, false, len(paths) > 0
+	}
+	return nil, false, false
+}
@@ -0,0 +1,309 @@
+// Package raster renders the subset of SVG that Pixela emits to images, so
+// graphs can be shown by clients that only display PNG.
+//
+// Supported are rect, circle, ellipse, line, polyline, polygon and path
+// elements (fill and stroke), text drawn with a built-in 5x7 bitmap font,
+// groups and nested svg elements with transforms, presentation attributes,
+// inline styles and simple <style> rules (tag, .class and #id selectors).
+// Gradients are drawn as the average of their stops. Clipping, masks,
+// filters, patterns and <use> are ignored.
+package raster
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+)
+
+// MaxDimension is the largest width or height of a rendered image.
+const MaxDimension = 8192
+
+// node is an element of the parsed document. Character data is kept as
+// children with an empty name so text keeps its order around <tspan>s.
+type node struct {
+	name     string
+	attrs    map[string]string
+	children []*node
+	text     string
+}
+
+// Rasterize renders svg at scale times its size in CSS pixels.
+func Rasterize(svg []byte, scale float64) (*image.RGBA, error) {
+	if scale <= 0 || math.IsNaN(scale) || math.IsInf(scale, 0) {
+		return nil, fmt.Errorf("invalid scale %v", scale)
+	}
+
+	root, err := parse(svg)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, viewBox := viewport(root)
+	w := int(math.Ceil(width * scale))
+	h := int(math.Ceil(height * scale))
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("SVG has no size")
+	}
+	if w > MaxDimension || h > MaxDimension {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large (at most %d on each side)", w, h, MaxDimension)
+	}
+
+	r := &renderer{
+		root:      root,
+		canvas:    newCanvas(w, h),
+		gradients: collectGradients(root),
+		width:     width,
+		height:    height,
+	}
+	r.rules = collectRules(root)
+
+	base := scaling(scale, scale)
+	if viewBox != nil {
+		base = base.mul(viewBoxTransform(viewBox, width, height, root.attrs["preserveAspectRatio"]))
+	}
+	r.render(root, defaultStyle(), base)
+	return r.canvas.img, nil
+}
+
+func parse(data []byte) (*node, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var root *node
+	var stack []*node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid SVG: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				n.attrs[attr.Name.Local] = attr.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, &node{text: string(t)})
+			}
+		}
+	}
+
+	if root == nil || root.name != "svg" {
+		return nil, errors.New("not an SVG document")
+	}
+	return root, nil
+}
+
+// viewport returns the size of the root element in CSS pixels and its
+// viewBox, if any. A missing size falls back to the viewBox, then to the
+// 300x150 default of replaced elements.
+func viewport(root *node) (width, height float64, viewBox []float64) {
+	if vb := numbers(root.attrs["viewBox"]); len(vb) == 4 && vb[2] > 0 && vb[3] > 0 {
+		viewBox = vb
+	}
+
+	width, okW := parseLength(root.attrs["width"], 0)
+	height, okH := parseLength(root.attrs["height"], 0)
+	switch {
+	case okW && okH:
+	case viewBox != nil && okW:
+		height = width * viewBox[3] / viewBox[2]
+	case viewBox != nil && okH:
+		width = height * viewBox[2] / viewBox[3]
+	case viewBox != nil:
+		width, height = viewBox[2], viewBox[3]
+	default:
+		if !okW {
+			width = 300
+		}
+		if !okH {
+			height = 150
+		}
+	}
+	return width, height, viewBox
+}
+
+// viewBoxTransform maps the viewBox onto the viewport, honouring the
+// alignment of preserveAspectRatio (meet only).
+func viewBoxTransform(vb []float64, width, height float64, aspect string) matrix {
+	sx, sy := width/vb[2], height/vb[3]
+	align := strings.Fields(aspect)
+	if len(align) > 0 && align[0] == "none" {
+		return scaling(sx, sy).mul(translation(-vb[0], -vb[1]))
+	}
+
+	s := math.Min(sx, sy)
+	tx, ty := 0.0, 0.0
+	mode := "xMidYMid"
+	if len(align) > 0 {
+		mode = align[0]
+	}
+	switch {
+	case strings.Contains(mode, "xMid"):
+		tx = (width - vb[2]*s) / 2
+	case strings.Contains(mode, "xMax"):
+		tx = width - vb[2]*s
+	}
+	switch {
+	case strings.Contains(mode, "YMid"):
+		ty = (height - vb[3]*s) / 2
+	case strings.Contains(mode, "YMax"):
+		ty = height - vb[3]*s
+	}
+	return translation(tx, ty).mul(scaling(s, s)).mul(translation(-vb[0], -vb[1]))
+}
+
+type renderer struct {
+	root      *node
+	canvas    *canvas
+	rules     []cssRule
+	gradients map[string]paint
+	// width and height resolve percentages.
+	width, height float64
+}
+
+func (r *renderer) render(n *node, parent style, m matrix) {
+	switch n.name {
+	case "", "defs", "clipPath", "mask", "symbol", "pattern", "marker", "title", "desc",
+		"metadata", "style", "linearGradient", "radialGradient", "filter", "script", "foreignObject":
+		return
+	}
+
+	st := r.computeStyle(n, parent)
+	if st.display == "none" {
+		return
+	}
+	m = m.mul(parseTransform(n.attrs["transform"]))
+
+	switch n.name {
+	case "svg":
+		if n != r.root {
+			x, _ := parseLength(n.attrs["x"], r.width)
+			y, _ := parseLength(n.attrs["y"], r.height)
+			m = m.mul(translation(x, y))
+		}
+		r.renderChildren(n, st, m)
+	case "g", "a", "switch":
+		r.renderChildren(n, st, m)
+	case "text":
+		r.drawText(n, st, m)
+	default:
+		if paths, closed, ok := r.shape(n); ok {
+			r.draw(paths, closed, st, m)
+		}
+	}
+}
+
+func (r *renderer) renderChildren(n *node, st style, m matrix) {
+	for _, child := range n.children {
+		r.render(child, st, m)
+	}
+}
+
+// draw fills and strokes the subpaths of a shape. Open subpaths are closed
+// for filling but not for stroking unless closed is set.
+func (r *renderer) draw(paths []subpath, closed bool, st style, m matrix) {
+	if st.visibility == "hidden" || st.visibility == "collapse" {
+		return
+	}
+
+	device := make([]subpath, 0, len(paths))
+	for _, p := range paths {
+		device = append(device, subpath{points: m.applyAll(p.points), closed: p.closed || closed})
+	}
+
+	if color, ok := st.fill.resolve(st.fillOpacity * st.opacity); ok {
+		polygons := make([][]point, 0, len(device))
+		for _, p := range device {
+			polygons = append(polygons, p.points)
+		}
+		r.canvas.fill(polygons, color, st.fillRule == "evenodd")
+	}
+
+	if color, ok := st.stroke.resolve(st.strokeOpacity * st.opacity); ok && st.strokeWidth > 0 {
+		width := st.strokeWidth * math.Sqrt(math.Abs(m.det()))
+		r.canvas.fill(strokePolygons(device, width, st.lineCap), color, false)
+	}
+}
+
+// drawText draws the text of a <text> element and its <tspan>s at the
+// position of the <text> element.
+func (r *renderer) drawText(n *node, st style, m matrix) {
+	if st.visibility == "hidden" || st.visibility == "collapse" {
+		return
+	}
+	color, ok := st.fill.resolve(st.fillOpacity * st.opacity)
+	if !ok {
+		return
+	}
+
+	text := collapseWhitespace(textContent(n))
+	if text == "" {
+		return
+	}
+
+	x, _ := parseLength(firstValue(n.attrs["x"]), r.width)
+	y, _ := parseLength(firstValue(n.attrs["y"]), r.height)
+	dx, _ := parseLength(firstValue(n.attrs["dx"]), r.width)
+	dy, _ := parseLength(firstValue(n.attrs["dy"]), r.height)
+	polygons := textPolygons(text, x+dx, y+dy, st.fontSize, st.textAnchor)
+	for i, polygon := range polygons {
+		polygons[i] = m.applyAll(polygon)
+	}
+	r.canvas.fill(polygons, color, false)
+}
+
+func textContent(n *node) string {
+	if n.name == "" {
+		return n.text
+	}
+	if n.name == "title" || n.name == "desc" {
+		return ""
+	}
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+// collapseWhitespace applies the default xml:space handling: newlines are
+// dropped, tabs become spaces, runs of spaces collapse and the ends are
+// trimmed.
+func collapseWhitespace(s string) string {
+	s = strings.ReplaceAll(s, "\r", "")
+	s = strings.ReplaceAll(s, "\n", "")
+	s = strings.ReplaceAll(s, "\t", " ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func firstValue(list string) string {
+	if fields := strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,287 @@
+package raster
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden PNGs in testdata")
+
+// The fixtures in testdata follow the markup Pixela emits for each graph
+// mode (heatmap cells with month and weekday labels, the shields-style
+// badge, the line chart). They are hand-written stand-ins rather than
+// downloads; replace one with captured output and run with -update to
+// refresh its golden PNG.
+var goldenTests = []struct {
+	name  string
+	scale float64
+}{
+	{"default", 1},
+	{"short", 2},
+	{"badge", 2},
+	{"line", 1},
+	{"dark", 1},
+}
+
+func TestRasterizeGolden(t *testing.T) {
+	for _, tt := range goldenTests {
+		t.Run(tt.name, func(t *testing.T) {
+			svg, err := os.ReadFile(filepath.Join("testdata", tt.name+".svg"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Rasterize(svg, tt.scale)
+			if err != nil {
+				t.Fatalf("Rasterize: %v", err)
+			}
+
+			goldenPath := filepath.Join("testdata", tt.name+".png")
+			if *update {
+				var buf bytes.Buffer
+				if err := png.Encode(&buf, got); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(goldenPath, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			f, err := os.Open(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			defer f.Close()
+			want, err := png.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareImages(t, got, want)
+		})
+	}
+}
+
+// TestRasterizeKnownPixels checks pixels whose color follows from the
+// fixture markup alone, so a golden PNG refreshed with -update cannot hide
+// a cell drawn in the wrong place or color.
+func TestRasterizeKnownPixels(t *testing.T) {
+	tests := []struct {
+		fixture string
+		scale   float64
+		x, y    int
+		want    color.RGBA
+		what    string
+	}{
+		// Heatmap cells are 10px squares 12px apart, offset by (30, 20)
+		{"default", 1, 5, 5, color.RGBA{0xff, 0xff, 0xff, 0xff}, "background"},
+		{"default", 1, 35, 25, color.RGBA{0xee, 0xee, 0xee, 0xff}, "empty cell 2023-01-01"},
+		{"default", 1, 35, 37, color.RGBA{0x21, 0x6e, 0x39, 0xff}, "cell 2023-01-02 with 10 commits"},
+		{"default", 1, 47, 73, color.RGBA{0x9b, 0xe9, 0xa8, 0xff}, "cell 2023-01-12 with 1 commit"},
+		{"default", 1, 35, 31, color.RGBA{0xff, 0xff, 0xff, 0xff}, "gap between two cells"},
+		{"dark", 1, 5, 5, color.RGBA{0x0d, 0x11, 0x17, 0xff}, "background"},
+		{"dark", 1, 35, 25, color.RGBA{0x16, 0x1b, 0x22, 0xff}, "empty cell 2023-01-01"},
+		{"dark", 1, 35, 49, color.RGBA{0x39, 0xd3, 0x53, 0xff}, "cell 2023-01-05 with 10 commits"},
+		// The badge halves are #555 and #4c1 under a gradient drawn as
+		// the average of its stops: #5d5d5d at 10% opacity
+		{"badge", 2, 2, 18, color.RGBA{0x56, 0x56, 0x56, 0xff}, "label half"},
+		{"badge", 2, 220, 18, color.RGBA{0x47, 0xc1, 0x19, 0xff}, "value half"},
+	}
+	images := make(map[string]*image.RGBA)
+	for _, tt := range tests {
+		img, ok := images[tt.fixture]
+		if !ok {
+			svg, err := os.ReadFile(filepath.Join("testdata", tt.fixture+".svg"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img, err = Rasterize(svg, tt.scale); err != nil {
+				t.Fatalf("Rasterize %s: %v", tt.fixture, err)
+			}
+			images[tt.fixture] = img
+		}
+		got := img.RGBAAt(tt.x, tt.y)
+		if colorDiff(got.R, tt.want.R) > 1 || colorDiff(got.G, tt.want.G) > 1 ||
+			colorDiff(got.B, tt.want.B) > 1 || got.A != tt.want.A {
+			t.Errorf("%s: %s at (%d, %d) = %v, want %v", tt.fixture, tt.what, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func colorDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// compareImages fails when the images differ in size or in any pixel by
+// more than a rounding error, which floating point differences between
+// platforms may cause.
+func compareImages(t *testing.T, got *image.RGBA, want image.Image) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("image is %v, want %v", got.Bounds(), want.Bounds())
+	}
+
+	const tolerance = 2
+	mismatches := 0
+	b := got.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, a1 := got.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x, y).RGBA()
+			if channelDiff(r1, r2) > tolerance || channelDiff(g1, g2) > tolerance ||
+				channelDiff(b1, b2) > tolerance || channelDiff(a1, a2) > tolerance {
+				if mismatches < 5 {
+					t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got.At(x, y), want.At(x, y))
+				}
+				mismatches++
+			}
+		}
+	}
+	if mismatches > 0 {
+		t.Errorf("%d pixel(s) differ from the golden image", mismatches)
+	}
+}
+
+// channelDiff returns the difference of two 16-bit color channels in 8-bit
+// steps.
+func channelDiff(a, b uint32) uint32 {
+	if a > b {
+		return (a - b) >> 8
+	}
+	return (b - a) >> 8
+}
+
+func TestRasterizeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		svg   string
+		scale float64
+		want  string
+	}{
+		{"not SVG", `<html><body></body></html>`, 1, "not an SVG document"},
+		{"not XML", `not xml`, 1, "not an SVG document"},
+		{"zero size", `<svg xmlns="http://www.w3.org/2000/svg" width="0" height="0"></svg>`, 1, "SVG has no size"},
+		{"zero width", `<svg xmlns="http://www.w3.org/2000/svg" width="0" height="20"></svg>`, 1, "SVG has no size"},
+		{"too large", `<svg xmlns="http://www.w3.org/2000/svg" width="10000" height="10"></svg>`, 1, "too large"},
+		{"too large when scaled", `<svg xmlns="http://www.w3.org/2000/svg" width="720" height="135"></svg>`, 16, "too large"},
+		{"invalid scale", `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"></svg>`, 0, "invalid scale"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Rasterize([]byte(tt.svg), tt.scale)
+			if err == nil {
+				t.Fatalf("Rasterize returned a %v image, want an error containing %q", img.Bounds(), tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRasterizeDefaultSize(t *testing.T) {
+	// Like a browser, an svg without width, height or viewBox is 300x150
+	img, err := Rasterize([]byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), 1)
+	if err != nil {
+		t.Fatalf("Rasterize: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 300, 150); got != want {
+		t.Errorf("image is %v, want %v", got, want)
+	}
+}
+
+func TestRasterizeMaxDimension(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1024" height="10"></svg>`)
+	img, err := Rasterize(svg, MaxDimension/1024)
+	if err != nil {
+		t.Fatalf("Rasterize at exactly MaxDimension: %v", err)
+	}
+	if got := img.Bounds().Dx(); got != MaxDimension {
+		t.Errorf("width = %d, want %d", got, MaxDimension)
+	}
+}
+
+// TestRasterizeNonFiniteInput feeds numbers that parse to NaN or infinity,
+// or overflow once computed with, which must neither panic nor poison the
+// image.
+func TestRasterizeNonFiniteInput(t *testing.T) {
+	elements := []string{
+		`<rect width="nan" height="nan" rx="2"/>`,
+		`<rect width="NaN" height="10" rx="2"/>`,
+		`<rect width="inf" height="inf" rx="2"/>`,
+		`<rect width="-Infinity" height="10"/>`,
+		`<rect width="1e400" height="1e400" rx="2"/>`,
+		`<rect x="1e308" y="1e308" width="1e308" height="1e308" rx="1e308"/>`,
+		`<rect width="1e308in" height="10"/>`,
+		`<rect width="10" height="10" rx="nan" ry="nan"/>`,
+		`<rect width="10" height="10" opacity="nan" stroke="#000" stroke-width="inf"/>`,
+		`<rect width="10" height="10" fill="rgb(nan, 0, 0)"/>`,
+		`<circle cx="5" cy="5" r="inf"/>`,
+		`<circle cx="5" cy="5" r="1e308"/>`,
+		`<ellipse cx="nan" cy="5" rx="5" ry="5"/>`,
+		`<path d="M0 0 A nan nan 0 0 1 10 10"/>`,
+		`<path d="M0 0 A 1e400 1e400 0 0 1 10 10"/>`,
+		`<path d="M1e308 1e308 A 1e308 1e308 0 1 1 -1e308 -1e308 Z"/>`,
+		`<path d="M0 0 L 1e308 1e308 L -1e308 1e308 Z"/>`,
+		`<g transform="scale(1e308) translate(1e308)"><rect width="10" height="10" rx="2"/></g>`,
+		`<g transform="rotate(nan)"><rect width="10" height="10"/></g>`,
+		`<text x="nan" y="inf" font-size="nanem">12</text>`,
+	}
+	for _, element := range elements {
+		t.Run(element, func(t *testing.T) {
+			svg := `<svg xmlns="http://www.w3.org/2000/svg" width="20" height="20">` + element + `</svg>`
+			if _, err := Rasterize([]byte(svg), 1); err != nil {
+				t.Errorf("Rasterize: %v", err)
+			}
+		})
+	}
+}
+
+func TestRasterizeNonFiniteSize(t *testing.T) {
+	for _, attrs := range []string{
+		`width="nan" height="10"`,
+		`width="inf" height="10"`,
+		`width="1e400" height="10"`,
+		`viewBox="0 0 nan nan"`,
+	} {
+		t.Run(attrs, func(t *testing.T) {
+			svg := `<svg xmlns="http://www.w3.org/2000/svg" ` + attrs + `></svg>`
+			img, err := Rasterize([]byte(svg), 1)
+			if err == nil && (img.Bounds().Dx() <= 0 || img.Bounds().Dx() > MaxDimension) {
+				t.Errorf("Rasterize returned a %v image", img.Bounds())
+			}
+		})
+	}
+}
+
+func TestParseLengthRejectsNonFinite(t *testing.T) {
+	for _, value := range []string{"nan", "NaN", "inf", "-Inf", "+Infinity", "1e400", "nan%", "1e308in"} {
+		if v, ok := parseLength(value, 100); ok {
+			t.Errorf("parseLength(%q) = %v, want it rejected", value, v)
+		}
+	}
+	if v, ok := parseLength("1e3px", 100); !ok || v != 1000 {
+		t.Errorf("parseLength(%q) = %v, %v, want 1000", "1e3px", v, ok)
+	}
+}
+
+func TestArcPointsDegenerate(t *testing.T) {
+	to := point{10, 10}
+	nan, inf := math.NaN(), math.Inf(1)
+	for _, radii := range [][2]float64{{nan, nan}, {inf, inf}, {nan, 5}, {1e308, 1e308}} {
+		points := arcPoints(point{0, 0}, radii[0], radii[1], 0, false, true, to)
+		if len(points) == 0 || points[len(points)-1] != to {
+			t.Errorf("arcPoints with radii %v = %v, want it to end at %v", radii, points, to)
+		}
+	}
+}
@@ -0,0 +1,518 @@
+package raster
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rgba is a non-premultiplied color with components between 0 and 1.
+type rgba struct {
+	r, g, b, a float64
+}
+
+// paint is the value of fill or stroke.
+type paint struct {
+	none    bool
+	current bool // currentColor, resolved once the element's color is known
+	color   rgba
+}
+
+// resolve returns the color to draw with, or false if nothing is drawn.
+func (p paint) resolve(opacity float64) (rgba, bool) {
+	if p.none {
+		return rgba{}, false
+	}
+	c := p.color
+	c.a *= clamp01(opacity)
+	return c, c.a > 0
+}
+
+// style holds the properties used for drawing. Properties are inherited as
+// in CSS, except that opacity multiplies down the tree instead of grouping.
+type style struct {
+	fill, stroke  paint
+	color         rgba
+	fillOpacity   float64
+	strokeOpacity float64
+	strokeWidth   float64
+	fillRule      string
+	lineCap       string
+	fontSize      float64
+	textAnchor    string
+	visibility    string
+
+	// Not inherited
+	display        string
+	opacity        float64
+	elementOpacity float64
+}
+
+func defaultStyle() style {
+	black := rgba{a: 1}
+	return style{
+		fill:          paint{color: black},
+		stroke:        paint{none: true},
+		color:         black,
+		fillOpacity:   1,
+		strokeOpacity: 1,
+		strokeWidth:   1,
+		fillRule:      "nonzero",
+		lineCap:       "butt",
+		fontSize:      16,
+		textAnchor:    "start",
+		visibility:    "visible",
+		opacity:       1,
+	}
+}
+
+// styleProperties are the presentation attributes that are also properties.
+var styleProperties = []string{
+	"fill", "stroke", "color", "fill-opacity", "stroke-opacity", "stroke-width", "fill-rule",
+	"stroke-linecap", "font-size", "text-anchor", "visibility", "display", "opacity",
+}
+
+// computeStyle applies presentation attributes, then matching <style> rules,
+// then the style attribute, in increasing order of precedence.
+func (r *renderer) computeStyle(n *node, parent style) style {
+	st := parent
+	st.display = ""
+	st.elementOpacity = 1
+
+	for _, name := range styleProperties {
+		if value, ok := n.attrs[name]; ok {
+			r.setProperty(&st, parent, name, value)
+		}
+	}
+	for _, rule := range r.rules {
+		if rule.matches(n) {
+			for _, decl := range rule.decls {
+				r.setProperty(&st, parent, decl[0], decl[1])
+			}
+		}
+	}
+	for _, decl := range declarations(n.attrs["style"]) {
+		r.setProperty(&st, parent, decl[0], decl[1])
+	}
+
+	if st.fill.current {
+		st.fill = paint{color: st.color}
+	}
+	if st.stroke.current {
+		st.stroke = paint{color: st.color}
+	}
+	st.opacity = parent.opacity * st.elementOpacity
+	return st
+}
+
+// setProperty sets one property. Values that cannot be parsed are ignored,
+// which keeps the inherited value.
+func (r *renderer) setProperty(st *style, parent style, name, value string) {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+	if value == "" || value == "inherit" {
+		return
+	}
+
+	switch name {
+	case "fill":
+		if p, ok := r.parsePaint(value); ok {
+			st.fill = p
+		}
+	case "stroke":
+		if p, ok := r.parsePaint(value); ok {
+			st.stroke = p
+		}
+	case "color":
+		if c, ok := parseColor(value); ok {
+			st.color = c
+		}
+	case "fill-opacity":
+		if v, ok := parseOpacity(value); ok {
+			st.fillOpacity = v
+		}
+	case "stroke-opacity":
+		if v, ok := parseOpacity(value); ok {
+			st.strokeOpacity = v
+		}
+	case "opacity":
+		if v, ok := parseOpacity(value); ok {
+			st.elementOpacity = v
+		}
+	case "stroke-width":
+		if v, ok := parseLength(value, 0); ok && v >= 0 {
+			st.strokeWidth = v
+		}
+	case "font-size":
+		if strings.HasSuffix(value, "em") {
+			if v, ok := parseNumber(strings.TrimSuffix(value, "em")); ok {
+				st.fontSize = v * parent.fontSize
+			}
+		} else if v, ok := parseLength(value, parent.fontSize); ok && v > 0 {
+			st.fontSize = v
+		}
+	case "fill-rule":
+		st.fillRule = value
+	case "stroke-linecap":
+		st.lineCap = value
+	case "text-anchor":
+		st.textAnchor = value
+	case "visibility":
+		st.visibility = value
+	case "display":
+		st.display = value
+	}
+}
+
+var urlPattern = regexp.MustCompile(`^url\(\s*['"]?#([^'")]+)['"]?\s*\)\s*(.*)$`)
+
+func (r *renderer) parsePaint(value string) (paint, bool) {
+	switch value {
+	case "none", "transparent":
+		return paint{none: true}, true
+	case "currentColor":
+		return paint{current: true}, true
+	}
+
+	if m := urlPattern.FindStringSubmatch(value); m != nil {
+		if p, ok := r.gradients[m[1]]; ok {
+			return p, true
+		}
+		if m[2] != "" {
+			return r.parsePaint(m[2])
+		}
+		return paint{none: true}, true
+	}
+
+	c, ok := parseColor(value)
+	return paint{color: c}, ok
+}
+
+func parseOpacity(value string) (float64, bool) {
+	if strings.HasSuffix(value, "%") {
+		v, ok := parseNumber(strings.TrimSuffix(value, "%"))
+		return clamp01(v / 100), ok
+	}
+	v, ok := parseNumber(value)
+	return clamp01(v), ok
+}
+
+// parseNumber parses a finite number. strconv.ParseFloat also accepts NaN
+// and infinities, which no attribute may hold: they would poison every
+// coordinate computed from them.
+func parseNumber(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseLength parses a length in user units. Percentages are relative to
+// ref; font-relative units assume the default font size.
+func parseLength(value string, ref float64) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	factor := 1.0
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{
+		{"%", ref / 100}, {"px", 1}, {"pt", 4.0 / 3}, {"pc", 16}, {"mm", 96 / 25.4},
+		{"cm", 96 / 2.54}, {"in", 96}, {"em", 16}, {"ex", 8},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			factor = unit.factor
+			break
+		}
+	}
+
+	v, ok := parseNumber(strings.TrimSpace(value))
+	if !ok {
+		return 0, false
+	}
+	// Large lengths may still overflow once scaled
+	if v *= factor; math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, false
+	}
+	return v, true
+}
+
+var namedColors = map[string]rgba{
+	"black":     {0, 0, 0, 1},
+	"white":     {1, 1, 1, 1},
+	"red":       {1, 0, 0, 1},
+	"green":     {0, 128.0 / 255, 0, 1},
+	"blue":      {0, 0, 1, 1},
+	"yellow":    {1, 1, 0, 1},
+	"orange":    {1, 165.0 / 255, 0, 1},
+	"purple":    {128.0 / 255, 0, 128.0 / 255, 1},
+	"gray":      {128.0 / 255, 128.0 / 255, 128.0 / 255, 1},
+	"grey":      {128.0 / 255, 128.0 / 255, 128.0 / 255, 1},
+	"silver":    {192.0 / 255, 192.0 / 255, 192.0 / 255, 1},
+	"lightgray": {211.0 / 255, 211.0 / 255, 211.0 / 255, 1},
+	"lightgrey": {211.0 / 255, 211.0 / 255, 211.0 / 255, 1},
+	"darkgray":  {169.0 / 255, 169.0 / 255, 169.0 / 255, 1},
+	"darkgrey":  {169.0 / 255, 169.0 / 255, 169.0 / 255, 1},
+	"navy":      {0, 0, 128.0 / 255, 1},
+	"teal":      {0, 128.0 / 255, 128.0 / 255, 1},
+	"maroon":    {128.0 / 255, 0, 0, 1},
+	"olive":     {128.0 / 255, 128.0 / 255, 0, 1},
+	"lime":      {0, 1, 0, 1},
+	"aqua":      {0, 1, 1, 1},
+	"cyan":      {0, 1, 1, 1},
+	"fuchsia":   {1, 0, 1, 1},
+	"magenta":   {1, 0, 1, 1},
+}
+
+var rgbPattern = regexp.MustCompile(`^rgba?\(([^)]*)\)$`)
+
+// parseColor parses hex, rgb()/rgba() and the common named colors.
+func parseColor(value string) (rgba, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if c, ok := namedColors[value]; ok {
+		return c, true
+	}
+
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			var expanded strings.Builder
+			for _, ch := range hex {
+				expanded.WriteRune(ch)
+				expanded.WriteRune(ch)
+			}
+			hex = expanded.String()
+		}
+		if len(hex) != 6 && len(hex) != 8 {
+			return rgba{}, false
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return rgba{}, false
+		}
+		return rgba{
+			r: float64(v>>24&0xff) / 255,
+			g: float64(v>>16&0xff) / 255,
+			b: float64(v>>8&0xff) / 255,
+			a: float64(v&0xff) / 255,
+		}, true
+	}
+
+	if m := rgbPattern.FindStringSubmatch(value); m != nil {
+		parts := strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) != 3 && len(parts) != 4 {
+			return rgba{}, false
+		}
+		var components [4]float64
+		components[3] = 1
+		for i, part := range parts {
+			var v float64
+			var ok bool
+			if strings.HasSuffix(part, "%") {
+				v, ok = parseNumber(strings.TrimSuffix(part, "%"))
+				v /= 100
+			} else if v, ok = parseNumber(part); i < 3 {
+				v /= 255
+			}
+			if !ok {
+				return rgba{}, false
+			}
+			components[i] = clamp01(v)
+		}
+		return rgba{components[0], components[1], components[2], components[3]}, true
+	}
+	return rgba{}, false
+}
+
+// collectGradients returns a flat paint for every gradient: the average of
+// its stops, weighted by their opacity.
+func collectGradients(root *node) map[string]paint {
+	gradients := make(map[string]paint)
+	hrefs := make(map[string]string)
+
+	var walk func(n *node)
+	walk = func(n *node) {
+		if id := n.attrs["id"]; id != "" && (n.name == "linearGradient" || n.name == "radialGradient") {
+			if p, ok := averageStops(n); ok {
+				gradients[id] = p
+			} else if href := n.attrs["href"]; strings.HasPrefix(href, "#") {
+				hrefs[id] = href[1:]
+			}
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	for id, target := range hrefs {
+		if p, ok := gradients[target]; ok {
+			gradients[id] = p
+		}
+	}
+	return gradients
+}
+
+func averageStops(gradient *node) (paint, bool) {
+	var sum rgba
+	stops := 0
+	for _, stop := range gradient.children {
+		if stop.name != "stop" {
+			continue
+		}
+		props := map[string]string{"stop-color": stop.attrs["stop-color"], "stop-opacity": stop.attrs["stop-opacity"]}
+		for _, decl := range declarations(stop.attrs["style"]) {
+			props[decl[0]] = decl[1]
+		}
+
+		c, ok := parseColor(props["stop-color"])
+		if !ok {
+			c = rgba{a: 1}
+		}
+		if v, ok := parseOpacity(props["stop-opacity"]); ok {
+			c.a *= v
+		}
+		sum.r += c.r * c.a
+		sum.g += c.g * c.a
+		sum.b += c.b * c.a
+		sum.a += c.a
+		stops++
+	}
+
+	if stops == 0 {
+		return paint{}, false
+	}
+	if sum.a == 0 {
+		return paint{none: true}, true
+	}
+	return paint{color: rgba{sum.r / sum.a, sum.g / sum.a, sum.b / sum.a, sum.a / float64(stops)}}, true
+}
+
+// declarations parses "name: value; ..." as found in style attributes and
+// rule bodies.
+func declarations(s string) [][2]string {
+	var decls [][2]string
+	for _, decl := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		decls = append(decls, [2]string{strings.TrimSpace(name), strings.TrimSpace(value)})
+	}
+	return decls
+}
+
+// cssRule is a rule of a <style> element with a simple selector.
+type cssRule struct {
+	tag         string
+	id          string
+	classes     []string
+	specificity int
+	decls       [][2]string
+}
+
+func (c cssRule) matches(n *node) bool {
+	if c.tag != "" && c.tag != "*" && c.tag != n.name {
+		return false
+	}
+	if c.id != "" && c.id != n.attrs["id"] {
+		return false
+	}
+	classes := strings.Fields(n.attrs["class"])
+	for _, class := range c.classes {
+		found := false
+		for _, have := range classes {
+			if have == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+var cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// collectRules parses the rules of all <style> elements, ordered by
+// specificity. Selectors with combinators, pseudo-classes or attributes,
+// and at-rules, are skipped.
+func collectRules(root *node) []cssRule {
+	var css strings.Builder
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.name == "style" {
+			css.WriteString(textContent(n))
+			css.WriteString("\n")
+			return
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	var rules []cssRule
+	for _, block := range strings.Split(cssComment.ReplaceAllString(css.String(), ""), "}") {
+		selectors, body, ok := strings.Cut(block, "{")
+		if !ok || strings.Contains(selectors, "@") || strings.Contains(body, "{") {
+			continue
+		}
+		decls := declarations(body)
+		for _, selector := range strings.Split(selectors, ",") {
+			if rule, ok := parseSelector(strings.TrimSpace(selector)); ok {
+				rule.decls = decls
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].specificity < rules[j].specificity })
+	return rules
+}
+
+var selectorPattern = regexp.MustCompile(`^([a-zA-Z*][\w-]*)?((?:[.#][\w-]+)*)$`)
+var selectorPartPattern = regexp.MustCompile(`[.#][\w-]+`)
+
+func parseSelector(selector string) (cssRule, bool) {
+	m := selectorPattern.FindStringSubmatch(selector)
+	if selector == "" || m == nil {
+		return cssRule{}, false
+	}
+
+	rule := cssRule{tag: m[1]}
+	if rule.tag != "" && rule.tag != "*" {
+		rule.specificity++
+	}
+	for _, part := range selectorPartPattern.FindAllString(m[2], -1) {
+		if part[0] == '#' {
+			rule.id = part[1:]
+			rule.specificity += 100
+		} else {
+			rule.classes = append(rule.classes, part[1:])
+			rule.specificity += 10
+		}
+	}
+	return rule, true
+}
+
+func clamp01(v float64) float64 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 1:
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	redactedPlaceholder = "[REDACTED]"
+	// minSecretLength keeps short values from being redacted, which would
+	// mangle unrelated text. Pixela tokens are at least 8 characters long.
+	minSecretLength = 8
+)
+
+var (
+	// webhookPathPattern matches webhook hashes in API URLs, as found in
+	// transport errors.
+	webhookPathPattern = regexp.MustCompile(`(/webhooks/)[^/\s"'?#:,)]+`)
+	// slackWebhookPattern matches the secret part of the Slack Incoming
+	// Webhook URLs of channels.
+	slackWebhookPattern = regexp.MustCompile(`(hooks\.slack\.com/services/)[^\s"'<>]+`)
+)
+
+// secretArguments are the tool arguments whose values are secrets.
+var secretArguments = []string{"token", "newToken", "webhookHash"}
+
+// redactor scrubs secrets from every log line, error message and tool result.
+var redactor = &Redactor{active: make(map[string]int)}
+
+// Redactor replaces known secrets with a placeholder. The configured tokens
+// are always known; the secret arguments of a tool call are known while the
+// call runs.
+type Redactor struct {
+	mu sync.RWMutex
+	// configured returns the tokens from the config and profiles, which
+	// may change when the profiles are reloaded.
+	configured func() []string
+	active     map[string]int
+}
+
+// SetConfigured sets the source of the configured tokens.
+func (r *Redactor) SetConfigured(configured func() []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configured = configured
+}
+
+// Track adds the values of a call's secret arguments until the returned func
+// is called.
+func (r *Redactor) Track(arguments map[string]interface{}) (release func()) {
+	var values []string
+	for _, name := range secretArguments {
+		if value, ok := arguments[name].(string); ok && len(value) >= minSecretLength {
+			values = append(values, value)
+		}
+	}
+
+	r.mu.Lock()
+	for _, value := range values {
+		r.active[value]++
+	}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, value := range values {
+			if r.active[value]--; r.active[value] <= 0 {
+				delete(r.active, value)
+			}
+		}
+	}
+}
+
+func (r *Redactor) secrets() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var secrets []string
+	if r.configured != nil {
+		for _, secret := range r.configured() {
+			if len(secret) >= minSecretLength {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	for secret := range r.active {
+		secrets = append(secrets, secret)
+	}
+	// Longest first, so a secret containing another is replaced whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
+
+// Redact returns s with every known secret and the webhook hashes and Slack
+// webhook paths in URLs replaced.
+func (r *Redactor) Redact(s string) string {
+	for _, secret := range r.secrets() {
+		s = strings.ReplaceAll(s, secret, redactedPlaceholder)
+	}
+	s = webhookPathPattern.ReplaceAllString(s, "${1}"+redactedPlaceholder)
+	return slackWebhookPattern.ReplaceAllString(s, "${1}"+redactedPlaceholder)
+}
+
+// RedactValue redacts every string within a tool result or error data.
+// Maps and slices are redacted in place; other values (typed tool outputs)
+// are replaced by a redacted JSON equivalent if they contain a secret.
+func (r *Redactor) RedactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, float64, int:
+		return v
+	case string:
+		return r.Redact(v)
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = r.RedactValue(elem)
+		}
+		return v
+	case []map[string]interface{}:
+		for _, elem := range v {
+			r.RedactValue(elem)
+		}
+		return v
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = r.RedactValue(elem)
+		}
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil || r.Redact(string(data)) == string(data) {
+			return v
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return redactedPlaceholder
+		}
+		return r.RedactValue(generic)
+	}
+}
+
+// redactingWriter is installed as the log output so no log line carries a
+// secret.
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
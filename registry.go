@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/a-know/pixela-mcp/pixela"
+)
+
+// JSONSchema is the subset of JSON Schema used to describe tool arguments
+// and results.
+type JSONSchema struct {
+	Type        string                 `json:"type"`
+	Description string                 `json:"description,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Pattern     string                 `json:"pattern,omitempty"`
+
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+
+	patternRE *regexp.Regexp
+}
+
+// Tool is a single MCP tool. Its input schema is derived from the typed
+// argument struct of its handler, so tools/list and tools/call always agree.
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema *JSONSchema
+	// OutputSchema describes the structuredContent of successful results,
+	// if the tool returns data.
+	OutputSchema *JSONSchema
+	// Title is the human-readable name shown by clients.
+	Title string
+	// Method is the HTTP method of the Pixela API call the tool makes, or
+	// empty for tools answered by the server itself.
+	Method string
+
+	// credentialFreeSchema is InputSchema without username and token,
+	// advertised when the server has default credentials. It is nil for
+	// tools that do not take Credentials.
+	credentialFreeSchema *JSONSchema
+
+	call func(s *MCPServer, ctx context.Context, client *pixela.Client, arguments map[string]interface{}) map[string]interface{}
+}
+
+// NewTool builds a Tool from a handler taking a typed argument struct.
+// Argument names come from the `json` tags of T, descriptions from the
+// `description` tags, and every field without `omitempty` is required.
+// String fields may be constrained with `enum:"a,b"` and `pattern:"..."` tags.
+func NewTool[T any](name, description string, handler func(s *MCPServer, ctx context.Context, client *pixela.Client, args T) map[string]interface{}) *Tool {
+	var zero T
+	schema := schemaForType(reflect.TypeOf(zero))
+
+	tool := &Tool{
+		Name:        name,
+		Description: description,
+		InputSchema: schema,
+		call: func(s *MCPServer, ctx context.Context, client *pixela.Client, arguments map[string]interface{}) map[string]interface{} {
+			var args T
+			if err := decodeArguments(arguments, &args); err != nil {
+				return s.createErrorResult(fmt.Sprintf("Invalid arguments: %v", err))
+			}
+
+			return handler(s, ctx, client, args)
+		},
+	}
+	for _, credentials := range []reflect.Type{reflect.TypeOf(Credentials{}), reflect.TypeOf(ReadCredentials{})} {
+		if reflect.TypeOf(zero) == credentials || embedsType(reflect.TypeOf(zero), credentials) {
+			tool.credentialFreeSchema = schema.without("username", "token")
+		}
+	}
+	return tool
+}
+
+// UsesCredentials reports whether the tool authenticates as a Pixela user
+// through embedded Credentials or ReadCredentials, and so can use the
+// default credentials.
+func (t *Tool) UsesCredentials() bool {
+	return t.credentialFreeSchema != nil
+}
+
+func (t *Tool) WithTitle(title string) *Tool {
+	t.Title = title
+	return t
+}
+
+// WithMethod records the HTTP method of the Pixela API call behind the tool.
+func (t *Tool) WithMethod(method string) *Tool {
+	t.Method = method
+	return t
+}
+
+// ReadOnly reports whether the tool leaves everything on Pixela unchanged.
+func (t *Tool) ReadOnly() bool {
+	return t.Method == "" || t.Method == http.MethodGet
+}
+
+// ToolAnnotations are the behaviour hints of a tool, introduced in MCP
+// 2025-03-26.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
+}
+
+// Annotations derives the hints from the HTTP method of the Pixela call:
+// GET only reads, POST adds, PUT may overwrite and DELETE destroys. As for
+// retries, PUT is not considered idempotent because Pixela's /increment,
+// /decrement, /add and /subtract endpoints use it. Tools answered by the
+// server itself only read local state.
+func (t *Tool) Annotations() ToolAnnotations {
+	annotations := ToolAnnotations{Title: t.Title, OpenWorldHint: t.Method != ""}
+	switch t.Method {
+	case "", http.MethodGet:
+		annotations.ReadOnlyHint = true
+		annotations.IdempotentHint = true
+	case http.MethodPut:
+		annotations.DestructiveHint = true
+	case http.MethodDelete:
+		annotations.DestructiveHint = true
+		annotations.IdempotentHint = true
+	}
+	return annotations
+}
+
+// WithOutput declares the type of the data the tool returns, from which its
+// outputSchema is derived.
+func (t *Tool) WithOutput(output interface{}) *Tool {
+	t.OutputSchema = schemaForType(reflect.TypeOf(output))
+	return t
+}
+
+// ToolRegistry holds the tools exposed by the server in registration order.
+type ToolRegistry struct {
+	tools  []*Tool
+	byName map[string]*Tool
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		byName: make(map[string]*Tool),
+	}
+}
+
+// Register adds tools to the registry. Registering the same name twice is a
+// programming error and panics.
+func (r *ToolRegistry) Register(tools ...*Tool) {
+	for _, tool := range tools {
+		if _, exists := r.byName[tool.Name]; exists {
+			panic(fmt.Sprintf("tool %q registered twice", tool.Name))
+		}
+		r.tools = append(r.tools, tool)
+		r.byName[tool.Name] = tool
+	}
+}
+
+func (r *ToolRegistry) Lookup(name string) (*Tool, bool) {
+	tool, ok := r.byName[name]
+	return tool, ok
+}
+
+// listOptions adapts the tool definitions returned by List to a session.
+type listOptions struct {
+	// features are those of the negotiated protocol revision.
+	features protocolFeatures
+	// hideCredentials leaves username and token out of the input schemas
+	// since the server fills them in.
+	hideCredentials bool
+	// profileArgument adds the optional profile argument to every tool.
+	profileArgument bool
+	// policy leaves out the tools it does not allow.
+	policy Policy
+	// elicitation hides the confirm argument of destructive tools, which
+	// then ask the user directly; otherwise confirm is required.
+	elicitation bool
+}
+
+// profileSchema describes the profile argument accepted by every tool when
+// credential profiles are configured.
+var profileSchema = &JSONSchema{
+	Type:        "string",
+	Description: "Credential profile to use instead of username/token (see list_profiles)",
+}
+
+// List returns the tool definitions in the shape expected by tools/list.
+func (r *ToolRegistry) List(opts listOptions) []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(r.tools))
+	for _, tool := range r.tools {
+		if !opts.policy.Allows(tool) {
+			continue
+		}
+		inputSchema := tool.InputSchema
+		if opts.hideCredentials && tool.UsesCredentials() {
+			inputSchema = tool.credentialFreeSchema
+		}
+		if opts.profileArgument {
+			inputSchema = inputSchema.with("profile", profileSchema)
+			if tool.UsesCredentials() {
+				// A profile stands in for them
+				inputSchema = inputSchema.optional("username", "token")
+			}
+		}
+		if _, confirmable := inputSchema.Properties["confirm"]; confirmable {
+			if opts.elicitation {
+				inputSchema = inputSchema.without("confirm")
+			} else {
+				inputSchema = inputSchema.require("confirm")
+			}
+		}
+		def := map[string]interface{}{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"inputSchema": inputSchema,
+		}
+		if opts.features.ToolAnnotations {
+			def["annotations"] = tool.Annotations()
+		}
+		if opts.features.StructuredOutput && tool.OutputSchema != nil {
+			def["outputSchema"] = tool.OutputSchema
+		}
+		list = append(list, def)
+	}
+	return list
+}
+
+func decodeArguments(arguments map[string]interface{}, out interface{}) error {
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// without returns a shallow copy of an object schema lacking the named
+// properties.
+func (schema *JSONSchema) without(names ...string) *JSONSchema {
+	copied := schema.optional(names...)
+	copied.Properties = make(map[string]*JSONSchema, len(schema.Properties))
+	for name, prop := range schema.Properties {
+		if !containsString(names, name) {
+			copied.Properties[name] = prop
+		}
+	}
+	return copied
+}
+
+// optional returns a shallow copy of an object schema in which the named
+// properties are not required.
+func (schema *JSONSchema) optional(names ...string) *JSONSchema {
+	copied := *schema
+	copied.Required = nil
+	for _, name := range schema.Required {
+		if !containsString(names, name) {
+			copied.Required = append(copied.Required, name)
+		}
+	}
+	return &copied
+}
+
+// require returns a shallow copy of an object schema in which the named
+// property is required.
+func (schema *JSONSchema) require(name string) *JSONSchema {
+	copied := *schema
+	copied.Required = append(append([]string(nil), schema.Required...), name)
+	return &copied
+}
+
+// with returns a shallow copy of an object schema with an added property.
+func (schema *JSONSchema) with(name string, prop *JSONSchema) *JSONSchema {
+	copied := *schema
+	copied.Properties = make(map[string]*JSONSchema, len(schema.Properties)+1)
+	for n, p := range schema.Properties {
+		copied.Properties[n] = p
+	}
+	copied.Properties[name] = prop
+	return &copied
+}
+
+// embedsType reports whether struct type t embeds target, directly or
+// through other embedded structs.
+func embedsType(t, target reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.Anonymous {
+			continue
+		}
+		if field.Type == target || embedsType(field.Type, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaForType(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaForType(t.Elem())}
+	case reflect.Struct:
+		additional := false
+		schema := &JSONSchema{
+			Type:                 "object",
+			Properties:           make(map[string]*JSONSchema),
+			AdditionalProperties: &additional,
+		}
+		addStructFields(schema, t)
+		return schema
+	default:
+		panic(fmt.Sprintf("unsupported argument type %s", t))
+	}
+}
+
+// addStructFields adds the fields of t to schema, flattening embedded structs
+// the same way encoding/json does.
+func addStructFields(schema *JSONSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" {
+			addStructFields(schema, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := schemaForType(field.Type)
+		prop.Description = field.Tag.Get("description")
+
+		// enum and pattern constrain the elements of array fields
+		constrained := prop
+		if prop.Type == "array" {
+			constrained = prop.Items
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			constrained.Enum = strings.Split(enum, ",")
+		}
+		if pattern := field.Tag.Get("pattern"); pattern != "" {
+			constrained.Pattern = pattern
+			constrained.patternRE = regexp.MustCompile(pattern)
+		}
+		schema.Properties[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+// The hints each kind of Pixela call should carry.
+var (
+	readHints      = ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true, OpenWorldHint: true}
+	addHints       = ToolAnnotations{OpenWorldHint: true}
+	overwriteHints = ToolAnnotations{DestructiveHint: true, OpenWorldHint: true}
+	destroyHints   = ToolAnnotations{DestructiveHint: true, IdempotentHint: true, OpenWorldHint: true}
+	localHints     = ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true}
+)
+
+func TestToolAnnotations(t *testing.T) {
+	want := map[string]struct {
+		title string
+		hints ToolAnnotations
+	}{
+		"create_user":          {"Create User", addHints},
+		"create_graph":         {"Create Graph", addHints},
+		"post_pixel":           {"Post Pixel", addHints},
+		"delete_user":          {"Delete User", destroyHints},
+		"update_user":          {"Update User Token", overwriteHints},
+		"update_user_profile":  {"Update User Profile", overwriteHints},
+		"get_user_profile":     {"Get User Profile", readHints},
+		"get_graphs":           {"List Graphs", readHints},
+		"get_graph_definition": {"Get Graph Definition", readHints},
+		"update_graph":         {"Update Graph", overwriteHints},
+		"delete_graph":         {"Delete Graph", destroyHints},
+		"get_pixels":           {"List Pixels", readHints},
+		"get_graph_stats":      {"Get Graph Statistics", readHints},
+		"get_graph_svg":        {"Get Graph SVG", readHints},
+		"get_graph_page":       {"Get Graph Page", readHints},
+		"get_profile_page":     {"Get Profile Page", readHints},
+		"render_graph_image":   {"Render Graph Image", readHints},
+		"batch_post_pixels":    {"Batch Post Pixels", addHints},
+		"get_pixel":            {"Get Pixel", readHints},
+		"get_latest_pixel":     {"Get Latest Pixel", readHints},
+		"get_today_pixel":      {"Get Today's Pixel", readHints},
+		"update_pixel":         {"Update Pixel", overwriteHints},
+		"delete_pixel":         {"Delete Pixel", destroyHints},
+		"increment_pixel":      {"Increment Today's Pixel", overwriteHints},
+		"decrement_pixel":      {"Decrement Today's Pixel", overwriteHints},
+		"create_webhook":       {"Create Webhook", addHints},
+		"get_webhooks":         {"List Webhooks", readHints},
+		"invoke_webhook":       {"Invoke Webhook", addHints},
+		"delete_webhook":       {"Delete Webhook", destroyHints},
+		"create_channel":       {"Create Channel", addHints},
+		"get_channels":         {"List Channels", readHints},
+		"update_channel":       {"Update Channel", overwriteHints},
+		"delete_channel":       {"Delete Channel", destroyHints},
+		"create_notification":  {"Create Notification", addHints},
+		"get_notifications":    {"List Notifications", readHints},
+		"update_notification":  {"Update Notification", overwriteHints},
+		"delete_notification":  {"Delete Notification", destroyHints},
+		"add_pixel":            {"Add to Today's Pixel", overwriteHints},
+		"subtract_pixel":       {"Subtract from Today's Pixel", overwriteHints},
+		"stopwatch":            {"Start/Stop Stopwatch", addHints},
+		"list_profiles":        {"List Profiles", localHints},
+	}
+
+	registry := newToolRegistry()
+	for _, tool := range registry.tools {
+		expected, ok := want[tool.Name]
+		if !ok {
+			t.Errorf("tool %s has no expected annotations; add it to this test", tool.Name)
+			continue
+		}
+		expected.hints.Title = expected.title
+		if got := tool.Annotations(); got != expected.hints {
+			t.Errorf("%s annotations = %+v, want %+v", tool.Name, got, expected.hints)
+		}
+	}
+	for name := range want {
+		if _, ok := registry.Lookup(name); !ok {
+			t.Errorf("expected tool %s is not registered", name)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// LegacySSEHandler serves the HTTP+SSE transport of MCP 2024-11-05 for
+// clients that predate Streamable HTTP. A client opens GET /sse, receives
+// an "endpoint" event naming /messages?sessionId=..., and POSTs its
+// messages there; every response arrives as a "message" event on the
+// stream. The session lasts as long as the stream.
+type LegacySSEHandler struct {
+	tools  *ToolRegistry
+	config *Config
+
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+}
+
+func NewLegacySSEHandler(tools *ToolRegistry, config *Config) *LegacySSEHandler {
+	return &LegacySSEHandler{
+		tools:    tools,
+		config:   config,
+		sessions: make(map[string]*sseSession),
+	}
+}
+
+// sseSession is the Transport of one stream's MCPServer. Unlike
+// Streamable HTTP, responses travel through the queue too, so Send waits
+// for room instead of dropping messages.
+type sseSession struct {
+	id     string
+	server *MCPServer
+	ctx    context.Context
+	cancel context.CancelFunc
+	events chan []byte
+}
+
+func (s *sseSession) Send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	select {
+	case s.events <- data:
+		return nil
+	case <-s.ctx.Done():
+		return fmt.Errorf("session %s closed", s.id)
+	}
+}
+
+// ServeStream handles GET /sse.
+func (h *LegacySSEHandler) ServeStream(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &sseSession{
+		id:     id,
+		ctx:    ctx,
+		cancel: cancel,
+		events: make(chan []byte, sseQueueSize),
+	}
+	session.server = NewMCPServer(h.tools, h.config, session)
+	session.server.watchProfiles(ctx)
+
+	stream, ok := newSSEStream(w)
+	if !ok {
+		cancel()
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	h.mu.Lock()
+	h.sessions[id] = session
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.sessions, id)
+		h.mu.Unlock()
+		// Cancels the session's in-flight calls
+		cancel()
+	}()
+
+	if err := stream.event("endpoint", []byte("/messages?sessionId="+id)); err != nil {
+		return
+	}
+	stream.serve(r.Context(), ctx, session.events)
+}
+
+// ServeMessage handles POST /messages?sessionId=...; the response is
+// delivered on the session's stream, the POST itself only gets 202.
+func (h *LegacySSEHandler) ServeMessage(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	session, ok := h.sessions[r.URL.Query().Get("sessionId")]
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var req MCPRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Printf("Error parsing JSON: %v", err)
+		http.Error(w, "Parse error", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case req.Method == "":
+		session.server.handleResponse(body)
+	case req.IsNotification():
+		session.server.handleNotification(req)
+	case req.Method == "initialize":
+		// Answer before acknowledging so that following requests see the
+		// initialized state
+		session.server.sendResponse(*session.server.handleRequest(session.ctx, req))
+	default:
+		// Checked in arrival order, like on stdio
+		if err := session.server.checkLifecycle(req.Method); err != nil {
+			session.server.sendResponse(MCPResponse{JSONRPC: "2.0", ID: req.ID, Error: err})
+			break
+		}
+
+		// Waiting for a worker slot holds back the POST, not the stream
+		reqCtx, done := session.server.trackRequest(session.ctx, req.ID)
+		if !session.server.acquireWorker(reqCtx, req) {
+			done()
+			break
+		}
+		go func() {
+			defer done()
+			if response := session.server.serveRequest(reqCtx, req); response != nil {
+				session.server.sendResponse(*response)
+			}
+		}()
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *LegacySSEHandler) closeSessions() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, session := range h.sessions {
+		delete(h.sessions, id)
+		session.cancel()
+	}
+}
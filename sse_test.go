@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one event read from an SSE stream.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSEEvents sends the events of an SSE stream on the returned channel
+// until the stream ends.
+func readSSEEvents(resp *http.Response) <-chan sseEvent {
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event.name != "":
+				events <- event
+				event = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+func nextSSEEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("SSE stream ended")
+		}
+		return event
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for an SSE event")
+		return sseEvent{}
+	}
+}
+
+func TestLegacySSE(t *testing.T) {
+	handler := NewLegacySSEHandler(newToolRegistry(), newTestConfig())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", handler.ServeStream)
+	mux.HandleFunc("/messages", handler.ServeMessage)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	t.Cleanup(handler.closeSessions)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/sse", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	events := readSSEEvents(stream)
+
+	endpoint := nextSSEEvent(t, events)
+	if endpoint.name != "endpoint" || !strings.HasPrefix(endpoint.data, "/messages?sessionId=") {
+		t.Fatalf("first event = %+v, want the endpoint", endpoint)
+	}
+
+	post := func(body string) int {
+		t.Helper()
+		resp, err := http.Post(server.URL+endpoint.data, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	receive := func(id float64) map[string]interface{} {
+		t.Helper()
+		event := nextSSEEvent(t, events)
+		if event.name != "message" {
+			t.Fatalf("event = %+v, want a message", event)
+		}
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(event.data), &message); err != nil {
+			t.Fatalf("message %s is not JSON: %v", event.data, err)
+		}
+		if message["id"] != id {
+			t.Fatalf("message = %v, want the response to request %v", message, id)
+		}
+		return message
+	}
+
+	if status := post(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`); status != http.StatusAccepted {
+		t.Fatalf("initialize status = %d, want 202", status)
+	}
+	result, _ := receive(1)["result"].(map[string]interface{})
+	if result["protocolVersion"] != "2024-11-05" {
+		t.Errorf("initialize result = %v, want protocol 2024-11-05", result)
+	}
+
+	if status := post(`{"jsonrpc":"2.0","method":"notifications/initialized"}`); status != http.StatusAccepted {
+		t.Errorf("notification status = %d, want 202", status)
+	}
+	if status := post(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); status != http.StatusAccepted {
+		t.Fatalf("tools/list status = %d, want 202", status)
+	}
+	result, _ = receive(2)["result"].(map[string]interface{})
+	if tools, _ := result["tools"].([]interface{}); len(tools) == 0 {
+		t.Errorf("tools/list result = %v, want the tools", result)
+	}
+
+	resp, err := http.Post(server.URL+"/messages?sessionId=unknown", "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":3,"method":"ping"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image/png"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/a-know/pixela-mcp/pixela"
+	"github.com/a-know/pixela-mcp/raster"
 )
 
 type ToolCallParams struct {
@@ -17,352 +26,659 @@ type ToolCallResult struct {
 	Content []map[string]interface{} `json:"content"`
 }
 
-func (s *MCPServer) handleToolsCall(params interface{}) map[string]interface{} {
-	// Convert parameters to map
-	paramsMap, ok := params.(map[string]interface{})
-	if !ok {
-		return s.createErrorResult("Invalid parameters")
-	}
+type Credentials struct {
+	Username string `json:"username" description:"User name"`
+	Token    string `json:"token" description:"Authentication token"`
+}
 
-	// Get tool name
-	toolName, ok := paramsMap["name"].(string)
-	if !ok {
-		return s.createErrorResult("Tool name not found")
-	}
+// ReadCredentials are the credentials of tools reading what Pixela also
+// shows publicly, for which the token is only needed to see secret graphs.
+// Like Credentials, they are filled in from the defaults or a profile.
+type ReadCredentials struct {
+	Username string `json:"username" description:"User name"`
+	Token    string `json:"token,omitempty" description:"Authentication token, only needed for secret graphs"`
+}
 
-	// Get tool arguments
-	arguments, ok := paramsMap["arguments"].(map[string]interface{})
-	if !ok {
-		return s.createErrorResult("Arguments not found")
-	}
+type GraphArgs struct {
+	Credentials
+	GraphID string `json:"graphID" description:"Graph ID"`
+}
 
-	client := pixela.NewClient()
+type PixelArgs struct {
+	GraphArgs
+	Date string `json:"date" description:"Date (yyyyMMdd format)" pattern:"^[0-9]{8}$"`
+}
 
-	switch toolName {
-	case "create_user":
-		return s.handleCreateUser(client, arguments)
-	case "create_graph":
-		return s.handleCreateGraph(client, arguments)
-	case "post_pixel":
-		return s.handlePostPixel(client, arguments)
-	case "delete_user":
-		return s.handleDeleteUser(client, arguments)
-	case "update_user":
-		return s.handleUpdateUser(client, arguments)
-	case "update_user_profile":
-		return s.handleUpdateUserProfile(client, arguments)
-	case "get_graphs":
-		return s.handleGetGraphs(client, arguments)
-	case "get_graph_definition":
-		return s.handleGetGraphDefinition(client, arguments)
-	case "update_graph":
-		return s.handleUpdateGraph(client, arguments)
-	case "delete_graph":
-		return s.handleDeleteGraph(client, arguments)
-	case "get_pixels":
-		return s.handleGetPixels(client, arguments)
-	case "get_graph_stats":
-		return s.handleGetGraphStats(client, arguments)
-	case "batch_post_pixels":
-		return s.handleBatchPostPixels(client, arguments)
-	case "get_pixel":
-		return s.handleGetPixel(client, arguments)
-	case "get_latest_pixel":
-		return s.handleGetLatestPixel(client, arguments)
-	case "get_today_pixel":
-		return s.handleGetTodayPixel(client, arguments)
-	case "update_pixel":
-		return s.handleUpdatePixel(client, arguments)
-	case "delete_pixel":
-		return s.handleDeletePixel(client, arguments)
-	case "increment_pixel":
-		return s.handleIncrementPixel(client, arguments)
-	case "decrement_pixel":
-		return s.handleDecrementPixel(client, arguments)
-	case "create_webhook":
-		return s.handleCreateWebhook(client, arguments)
-	case "get_webhooks":
-		return s.handleGetWebhooks(client, arguments)
-	case "invoke_webhook":
-		return s.handleInvokeWebhook(client, arguments)
-	case "delete_webhook":
-		return s.handleDeleteWebhook(client, arguments)
-	case "add_pixel":
-		return s.handleAddPixel(client, arguments)
-	case "subtract_pixel":
-		return s.handleSubtractPixel(client, arguments)
-	case "stopwatch":
-		return s.handleStopwatch(client, arguments)
-	default:
-		return s.createErrorResult(fmt.Sprintf("Unknown tool: %s", toolName))
-	}
-}
-
-func (s *MCPServer) handleCreateUser(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
+// CreateUserArgs names the user to create, so it does not embed Credentials
+// and never falls back to the default credentials.
+type CreateUserArgs struct {
+	Username            string `json:"username" description:"User name"`
+	Token               string `json:"token" description:"Authentication token"`
+	AgreeTermsOfService string `json:"agreeTermsOfService" description:"Agreement to the terms of service (yes/no)" enum:"yes,no"`
+	NotMinor            string `json:"notMinor" description:"Confirmation of not being a minor (yes/no)" enum:"yes,no"`
+}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
+type UpdateUserArgs struct {
+	Credentials
+	NewToken   string `json:"newToken" description:"New authentication token"`
+	ThanksCode string `json:"thanksCode,omitempty" description:"Thanks code (optional)"`
+}
 
-	agreeTermsOfService, ok := args["agreeTermsOfService"].(string)
-	if !ok {
-		return s.createErrorResult("agreeTermsOfService parameter is required")
-	}
+type UpdateUserProfileArgs struct {
+	Credentials
+	DisplayName       string   `json:"displayName,omitempty" description:"Display name"`
+	GravatarIconEmail string   `json:"gravatarIconEmail,omitempty" description:"Email address of the Gravatar icon to show"`
+	Title             string   `json:"title,omitempty" description:"Title shown under the display name"`
+	Timezone          string   `json:"timezone,omitempty" description:"Timezone, e.g. Asia/Tokyo"`
+	AboutURL          string   `json:"aboutURL,omitempty" description:"URL of a page about the user" pattern:"^https?://"`
+	ContributeURLs    []string `json:"contributeURLs,omitempty" description:"URLs of the user's contributions"`
+	PixelaGraph       string   `json:"pixelaGraph,omitempty" description:"ID of the graph shown on the profile page"`
+}
 
-	notMinor, ok := args["notMinor"].(string)
-	if !ok {
-		return s.createErrorResult("notMinor parameter is required")
-	}
+type CreateGraphArgs struct {
+	GraphArgs
+	Name                string `json:"name" description:"Graph name"`
+	Unit                string `json:"unit" description:"Unit"`
+	Type                string `json:"type" description:"Graph type (int/float)" enum:"int,float"`
+	Color               string `json:"color" description:"Graph color (shibafu: green, momiji: red, sora: blue, ichou: yellow, ajisai: purple, kuro: black)" enum:"shibafu,momiji,sora,ichou,ajisai,kuro"`
+	Timezone            string `json:"timezone,omitempty" description:"Timezone of the graph's dates, e.g. Asia/Tokyo (defaults to UTC)"`
+	SelfSufficient      string `json:"selfSufficient,omitempty" description:"Increment or decrement today's pixel by one when the graph's increment/decrement webhook or API is used without a pixel (increment/decrement/none)" enum:"increment,decrement,none"`
+	IsSecret            *bool  `json:"isSecret,omitempty" description:"Hide the graph from the graph list and require the token to view it"`
+	PublishOptionalData *bool  `json:"publishOptionalData,omitempty" description:"Publish the optional data of pixels on the graph page"`
+	StartOnMonday       *bool  `json:"startOnMonday,omitempty" description:"Start weeks on Monday instead of Sunday"`
+}
 
-	req := pixela.CreateUserRequest{
-		Token:               token,
-		Username:            username,
-		AgreeTermsOfService: agreeTermsOfService,
-		NotMinor:            notMinor,
-	}
+type UpdateGraphArgs struct {
+	GraphArgs
+	Name                string   `json:"name,omitempty" description:"Graph name"`
+	Unit                string   `json:"unit,omitempty" description:"Unit"`
+	Color               string   `json:"color,omitempty" description:"Graph color (shibafu: green, momiji: red, sora: blue, ichou: yellow, ajisai: purple, kuro: black)" enum:"shibafu,momiji,sora,ichou,ajisai,kuro"`
+	Timezone            string   `json:"timezone,omitempty" description:"Timezone"`
+	PurgeCacheURLs      []string `json:"purgeCacheURLs,omitempty" description:"Purge cache URLs"`
+	SelfSufficient      string   `json:"selfSufficient,omitempty" description:"Self-sufficient (increment/decrement/none)" enum:"increment,decrement,none"`
+	IsSecret            *bool    `json:"isSecret,omitempty" description:"Is secret graph"`
+	PublishOptionalData *bool    `json:"publishOptionalData,omitempty" description:"Publish optional data"`
+	StartOnMonday       *bool    `json:"startOnMonday,omitempty" description:"Start weeks on Monday instead of Sunday"`
+}
 
-	resp, err := client.CreateUser(req)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to create user: %v", err))
-	}
+type GetGraphSVGArgs struct {
+	ReadCredentials
+	GraphID     string `json:"graphID" description:"Graph ID"`
+	Date        string `json:"date,omitempty" description:"Last date shown in the graph (yyyyMMdd format, defaults to today)" pattern:"^[0-9]{8}$"`
+	Mode        string `json:"mode,omitempty" description:"Render mode: short (last 90 days), badge (today's quantity only) or line (line chart)" enum:"short,badge,line"`
+	Appearance  string `json:"appearance,omitempty" description:"Color scheme of the graph" enum:"dark"`
+	LessThan    string `json:"lessThan,omitempty" description:"Only show pixels whose quantity is less than this" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+	GreaterThan string `json:"greaterThan,omitempty" description:"Only show pixels whose quantity is greater than this" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("User '%s' was created successfully", username))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to create user: %s", resp.Message))
+func (a GetGraphSVGArgs) renderOptions() pixela.GraphRenderOptions {
+	return pixela.GraphRenderOptions{
+		Date:        a.Date,
+		Mode:        a.Mode,
+		Appearance:  a.Appearance,
+		LessThan:    a.LessThan,
+		GreaterThan: a.GreaterThan,
 	}
 }
 
-func (s *MCPServer) handleCreateGraph(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
+// The pages are public and fetched without the token, so their arguments do
+// not embed Credentials.
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
+type GetGraphPageArgs struct {
+	Username string `json:"username" description:"User name"`
+	GraphID  string `json:"graphID" description:"Graph ID"`
+	Mode     string `json:"mode,omitempty" description:"Page layout: simple (graph only) or simple-short (graph of the last 90 days only)" enum:"simple,simple-short"`
+	Fetch    bool   `json:"fetch,omitempty" description:"Also fetch the page and return a text summary and the outbound links of it"`
+}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+type GetProfilePageArgs struct {
+	Username string `json:"username" description:"User name"`
+	Fetch    bool   `json:"fetch,omitempty" description:"Also fetch the page and return a text summary and the outbound links of it"`
+}
 
-	name, ok := args["name"].(string)
-	if !ok {
-		return s.createErrorResult("name parameter is required")
-	}
+type GetUserProfileArgs struct {
+	Username string `json:"username" description:"User name"`
+}
 
-	unit, ok := args["unit"].(string)
-	if !ok {
-		return s.createErrorResult("unit parameter is required")
-	}
+const (
+	defaultImageScale = 2
+	maxImageScale     = 8
+)
 
-	graphType, ok := args["type"].(string)
-	if !ok {
-		return s.createErrorResult("type parameter is required")
-	}
+type RenderGraphImageArgs struct {
+	GetGraphSVGArgs
+	Scale float64 `json:"scale,omitempty" description:"Size of the image relative to the SVG (defaults to 2, at most 8)"`
+}
 
-	color, ok := args["color"].(string)
-	if !ok {
-		return s.createErrorResult("color parameter is required")
-	}
+type GetPixelsArgs struct {
+	GraphArgs
+	From     string `json:"from,omitempty" description:"Start date (yyyyMMdd format)" pattern:"^[0-9]{8}$"`
+	To       string `json:"to,omitempty" description:"End date (yyyyMMdd format)" pattern:"^[0-9]{8}$"`
+	WithBody bool   `json:"withBody,omitempty" description:"Return quantity and optional data along with each date"`
+}
 
-	req := pixela.CreateGraphRequest{
-		ID:    graphID,
-		Name:  name,
-		Unit:  unit,
-		Type:  graphType,
-		Color: color,
-	}
+type GetTodayPixelArgs struct {
+	GraphArgs
+	ReturnEmpty *bool `json:"returnEmpty,omitempty" description:"Return an empty pixel instead of an error when today's pixel does not exist"`
+}
 
-	resp, err := client.CreateGraph(username, token, req)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to create graph: %v", err))
-	}
+type PostPixelArgs struct {
+	GraphArgs
+	Date         string `json:"date,omitempty" description:"Date (yyyyMMdd format, defaults to today)" pattern:"^[0-9]{8}$"`
+	Quantity     string `json:"quantity" description:"Quantity" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+	OptionalData string `json:"optionalData,omitempty" description:"Optional data (JSON string)"`
+}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Graph '%s' was created successfully", name))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to create graph: %s", resp.Message))
-	}
+type UpdatePixelArgs struct {
+	PixelArgs
+	Quantity     string `json:"quantity" description:"Quantity" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+	OptionalData string `json:"optionalData,omitempty" description:"Optional data (optional)"`
+}
+
+type BatchPixel struct {
+	Date         string `json:"date" description:"Date (yyyyMMdd format)" pattern:"^[0-9]{8}$"`
+	Quantity     string `json:"quantity" description:"Quantity" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+	OptionalData string `json:"optionalData,omitempty" description:"Optional data (JSON string)"`
+}
+
+type BatchPostPixelsArgs struct {
+	GraphArgs
+	Pixels []BatchPixel `json:"pixels" description:"Pixels to post"`
+}
+
+type QuantityArgs struct {
+	GraphArgs
+	Quantity string `json:"quantity" description:"Value to apply to today's pixel" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+}
+
+type CreateWebhookArgs struct {
+	GraphArgs
+	Type     string `json:"type" description:"Webhook type (increment/decrement)" enum:"increment,decrement"`
+	Quantity string `json:"quantity,omitempty" description:"Quantity (optional)" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+}
+
+type InvokeWebhookArgs struct {
+	Username    string `json:"username" description:"User name"`
+	WebhookHash string `json:"webhookHash" description:"Webhook hash"`
+}
+
+type ChannelArgs struct {
+	Credentials
+	ChannelID string `json:"channelID" description:"Channel ID"`
+}
+
+// ChannelSettingsArgs are the settings of a channel, all of which Pixela
+// requires both on creation and on update.
+type ChannelSettingsArgs struct {
+	ChannelArgs
+	Name        string `json:"name" description:"Channel name"`
+	Type        string `json:"type,omitempty" description:"Channel type (defaults to slack, the only type Pixela supports)" enum:"slack"`
+	URL         string `json:"url" description:"Slack Incoming Webhook URL" pattern:"^https://"`
+	UserName    string `json:"userName" description:"Slack user name to post as"`
+	ChannelName string `json:"channelName" description:"Slack channel name to post to"`
+}
+
+type NotificationArgs struct {
+	GraphArgs
+	NotificationID string `json:"notificationID" description:"Notification ID"`
+}
+
+type CreateNotificationArgs struct {
+	NotificationArgs
+	Name      string `json:"name" description:"Notification name"`
+	Target    string `json:"target,omitempty" description:"What the condition applies to (defaults to quantity, the only target Pixela supports)" enum:"quantity"`
+	Condition string `json:"condition" description:"Condition on the day's quantity: greater than (>), equal to (=) or less than (<) the threshold, or a multiple of it (multipleOf)" enum:">,=,<,multipleOf"`
+	Threshold string `json:"threshold" description:"Value the quantity is compared with" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+	RemindBy  string `json:"remindBy,omitempty" description:"Hour of the day (0-23) at which the condition is checked and the channel notified" pattern:"^([0-9]|1[0-9]|2[0-3])$"`
+	ChannelID string `json:"channelID" description:"ID of the channel to notify"`
+}
+
+// The settings of UpdateNotificationArgs are pointers so that a setting left
+// out keeps its current value while an empty remindBy removes the reminder.
+type UpdateNotificationArgs struct {
+	NotificationArgs
+	Name      *string `json:"name,omitempty" description:"Notification name"`
+	Target    *string `json:"target,omitempty" description:"What the condition applies to" enum:"quantity"`
+	Condition *string `json:"condition,omitempty" description:"Condition on the day's quantity: greater than (>), equal to (=) or less than (<) the threshold, or a multiple of it (multipleOf)" enum:">,=,<,multipleOf"`
+	Threshold *string `json:"threshold,omitempty" description:"Value the quantity is compared with" pattern:"^-?[0-9]+(\\.[0-9]+)?$"`
+	RemindBy  *string `json:"remindBy,omitempty" description:"Hour of the day (0-23) at which the condition is checked and the channel notified; an empty string removes the hour" pattern:"^([0-9]|1[0-9]|2[0-3])?$"`
+	ChannelID *string `json:"channelID,omitempty" description:"ID of the channel to notify"`
+}
+
+// The arguments of destructive tools carry a confirm field for clients that
+// cannot be asked for confirmation through elicitation.
+
+type DeleteUserArgs struct {
+	Credentials
+	Confirm string `json:"confirm,omitempty" description:"Confirmation: the username of the user to delete"`
+}
+
+type DeleteGraphArgs struct {
+	GraphArgs
+	Confirm string `json:"confirm,omitempty" description:"Confirmation: the graphID of the graph to delete"`
+}
+
+type DeletePixelArgs struct {
+	PixelArgs
+	Confirm string `json:"confirm,omitempty" description:"Confirmation: the date of the pixel to delete"`
+}
+
+type DeleteWebhookArgs struct {
+	Credentials
+	WebhookHash string `json:"webhookHash" description:"Webhook hash"`
+	Confirm     string `json:"confirm,omitempty" description:"Confirmation: the webhookHash of the webhook to delete"`
+}
+
+type DeleteChannelArgs struct {
+	ChannelArgs
+	Confirm string `json:"confirm,omitempty" description:"Confirmation: the channelID of the channel to delete"`
+}
+
+type DeleteNotificationArgs struct {
+	NotificationArgs
+	Confirm string `json:"confirm,omitempty" description:"Confirmation: the notificationID of the notification to delete"`
+}
+
+type ListProfilesArgs struct{}
+
+type PixelOutput struct {
+	Date         string `json:"date" description:"Date (yyyyMMdd format)"`
+	Quantity     string `json:"quantity,omitempty" description:"Quantity"`
+	OptionalData string `json:"optionalData,omitempty" description:"Optional data"`
+}
+
+type PixelsOutput struct {
+	Pixels []PixelOutput `json:"pixels" description:"Pixels; quantity and optionalData are only set when withBody is true"`
+}
+
+type GraphDefinitionOutput struct {
+	ID                  string `json:"id" description:"Graph ID"`
+	Name                string `json:"name" description:"Graph name"`
+	Unit                string `json:"unit" description:"Unit"`
+	Type                string `json:"type" description:"Graph type (int/float)"`
+	Color               string `json:"color" description:"Graph color"`
+	Timezone            string `json:"timezone,omitempty" description:"Timezone"`
+	SelfSufficient      string `json:"selfSufficient,omitempty" description:"Self-sufficient (increment/decrement/none)"`
+	IsSecret            bool   `json:"isSecret" description:"Is secret graph"`
+	PublishOptionalData bool   `json:"publishOptionalData" description:"Publish optional data"`
+	StartOnMonday       bool   `json:"startOnMonday" description:"Weeks start on Monday"`
+}
+
+type GraphsOutput struct {
+	Graphs []GraphDefinitionOutput `json:"graphs" description:"Graph definitions"`
+}
+
+type GraphStatsOutput struct {
+	TotalPixelsCount  int    `json:"totalPixelsCount" description:"Number of pixels"`
+	MaxQuantity       string `json:"maxQuantity" description:"Maximum quantity"`
+	MinQuantity       string `json:"minQuantity" description:"Minimum quantity"`
+	MaxDate           string `json:"maxDate" description:"Date of the maximum quantity"`
+	MinDate           string `json:"minDate" description:"Date of the minimum quantity"`
+	TotalQuantity     string `json:"totalQuantity" description:"Total quantity"`
+	AvgQuantity       string `json:"avgQuantity" description:"Average quantity"`
+	TodaysQuantity    string `json:"todaysQuantity" description:"Today's quantity"`
+	YesterdayQuantity string `json:"yesterdayQuantity" description:"Yesterday's quantity"`
+}
+
+type WebhookOutput struct {
+	WebhookHash string `json:"webhookHash" description:"Webhook hash"`
+	GraphID     string `json:"graphID" description:"Graph ID"`
+	Type        string `json:"type" description:"Webhook type"`
+	Quantity    string `json:"quantity,omitempty" description:"Quantity"`
+}
+
+type WebhooksOutput struct {
+	Webhooks []WebhookOutput `json:"webhooks" description:"Webhooks"`
+}
+
+// ProfileOutput describes a credential profile. It never includes the token.
+type ProfileOutput struct {
+	Name     string `json:"name" description:"Profile name"`
+	Username string `json:"username" description:"User name"`
+	BaseURL  string `json:"baseURL,omitempty" description:"Pixela API base URL, when not the default"`
+}
+
+// ChannelOutput describes a channel. The Slack webhook URL is a secret and
+// is left out.
+type ChannelOutput struct {
+	ID          string `json:"id" description:"Channel ID"`
+	Name        string `json:"name" description:"Channel name"`
+	Type        string `json:"type" description:"Channel type"`
+	UserName    string `json:"userName,omitempty" description:"Slack user name to post as"`
+	ChannelName string `json:"channelName,omitempty" description:"Slack channel name to post to"`
+}
+
+type ChannelsOutput struct {
+	Channels []ChannelOutput `json:"channels" description:"Channels"`
+}
+
+type NotificationOutput struct {
+	ID        string `json:"id" description:"Notification ID"`
+	Name      string `json:"name" description:"Notification name"`
+	Target    string `json:"target" description:"What the condition applies to"`
+	Condition string `json:"condition" description:"Condition (>, =, < or multipleOf)"`
+	Threshold string `json:"threshold" description:"Value the quantity is compared with"`
+	RemindBy  string `json:"remindBy,omitempty" description:"Hour of the day (0-23) at which the condition is checked"`
+	ChannelID string `json:"channelID" description:"ID of the channel to notify"`
+}
+
+type NotificationsOutput struct {
+	Notifications []NotificationOutput `json:"notifications" description:"Notification rules of the graph"`
+}
+
+type PageOutput struct {
+	URL     string   `json:"url" description:"Page URL"`
+	Title   string   `json:"title,omitempty" description:"Page title, when fetched"`
+	Summary string   `json:"summary,omitempty" description:"Visible text of the page, when fetched"`
+	Links   []string `json:"links,omitempty" description:"Links from the page to other sites, when fetched (on a profile page, the about and contribute URLs)"`
 }
 
-func (s *MCPServer) handlePostPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
+// UserProfileOutput has the fields of UpdateUserProfileArgs, so that a
+// profile can be compared with an update.
+type UserProfileOutput struct {
+	URL               string   `json:"url" description:"Profile page URL"`
+	DisplayName       string   `json:"displayName,omitempty" description:"Display name"`
+	GravatarIconEmail string   `json:"gravatarIconEmail,omitempty" description:"Never returned, as the profile page does not show it"`
+	Title             string   `json:"title,omitempty" description:"Title shown under the display name"`
+	Timezone          string   `json:"timezone,omitempty" description:"Never returned, as the profile page does not show it"`
+	AboutURL          string   `json:"aboutURL,omitempty" description:"URL of a page about the user"`
+	ContributeURLs    []string `json:"contributeURLs,omitempty" description:"URLs of the user's contributions"`
+	PixelaGraph       string   `json:"pixelaGraph,omitempty" description:"ID of the graph shown on the profile page"`
+}
+
+type ProfilesOutput struct {
+	Profiles []ProfileOutput `json:"profiles" description:"Credential profiles"`
+}
+
+// newToolRegistry registers every tool exposed by the server.
+func newToolRegistry() *ToolRegistry {
+	registry := NewToolRegistry()
+	registry.Register(
+		NewTool("create_user", "Create a user on Pixela", (*MCPServer).handleCreateUser).WithTitle("Create User").WithMethod(http.MethodPost),
+		NewTool("create_graph", "Create a graph on Pixela", (*MCPServer).handleCreateGraph).WithTitle("Create Graph").WithMethod(http.MethodPost),
+		NewTool("post_pixel", "Post a pixel to Pixela", (*MCPServer).handlePostPixel).WithTitle("Post Pixel").WithMethod(http.MethodPost),
+		NewTool("delete_user", "Delete a user on Pixela", (*MCPServer).handleDeleteUser).WithTitle("Delete User").WithMethod(http.MethodDelete),
+		NewTool("update_user", "Update user information on Pixela", (*MCPServer).handleUpdateUser).WithTitle("Update User Token").WithMethod(http.MethodPut),
+		NewTool("update_user_profile", "Update user profile on Pixela", (*MCPServer).handleUpdateUserProfile).WithTitle("Update User Profile").WithMethod(http.MethodPut),
+		NewTool("get_user_profile", "Get the current profile of a user on Pixela, read from the public profile page as Pixela has no API that returns it. The Gravatar email address and the timezone are not on the page and are not returned", (*MCPServer).handleGetUserProfile).WithTitle("Get User Profile").WithMethod(http.MethodGet).WithOutput(UserProfileOutput{}),
+		NewTool("get_graphs", "Get a list of graphs on Pixela", (*MCPServer).handleGetGraphs).WithTitle("List Graphs").WithMethod(http.MethodGet).WithOutput(GraphsOutput{}),
+		NewTool("get_graph_definition", "Get graph definition on Pixela", (*MCPServer).handleGetGraphDefinition).WithTitle("Get Graph Definition").WithMethod(http.MethodGet).WithOutput(GraphDefinitionOutput{}),
+		NewTool("update_graph", "Update a graph on Pixela", (*MCPServer).handleUpdateGraph).WithTitle("Update Graph").WithMethod(http.MethodPut),
+		NewTool("delete_graph", "Delete a graph on Pixela", (*MCPServer).handleDeleteGraph).WithTitle("Delete Graph").WithMethod(http.MethodDelete),
+		NewTool("get_pixels", "Get a list of pixels on Pixela", (*MCPServer).handleGetPixels).WithTitle("List Pixels").WithMethod(http.MethodGet).WithOutput(PixelsOutput{}),
+		NewTool("get_graph_stats", "Get graph statistics on Pixela", (*MCPServer).handleGetGraphStats).WithTitle("Get Graph Statistics").WithMethod(http.MethodGet).WithOutput(GraphStatsOutput{}),
+		NewTool("get_graph_svg", "Get the SVG image of a graph on Pixela, with a shareable URL", (*MCPServer).handleGetGraphSVG).WithTitle("Get Graph SVG").WithMethod(http.MethodGet),
+		NewTool("get_graph_page", "Get the URL of the HTML detail page of a graph on Pixela, optionally with a text summary of the page", (*MCPServer).handleGetGraphPage).WithTitle("Get Graph Page").WithMethod(http.MethodGet).WithOutput(PageOutput{}),
+		NewTool("get_profile_page", "Get the URL of the public profile page of a user on Pixela, optionally with a text summary of the page and its links to other sites", (*MCPServer).handleGetProfilePage).WithTitle("Get Profile Page").WithMethod(http.MethodGet).WithOutput(PageOutput{}),
+		NewTool("render_graph_image", "Render a graph on Pixela as a PNG image", (*MCPServer).handleRenderGraphImage).WithTitle("Render Graph Image").WithMethod(http.MethodGet),
+		NewTool("batch_post_pixels", "Batch post pixels to Pixela", (*MCPServer).handleBatchPostPixels).WithTitle("Batch Post Pixels").WithMethod(http.MethodPost),
+		NewTool("get_pixel", "Get a specific pixel on Pixela", (*MCPServer).handleGetPixel).WithTitle("Get Pixel").WithMethod(http.MethodGet).WithOutput(PixelOutput{}),
+		NewTool("get_latest_pixel", "Get the latest pixel on Pixela", (*MCPServer).handleGetLatestPixel).WithTitle("Get Latest Pixel").WithMethod(http.MethodGet).WithOutput(PixelOutput{}),
+		NewTool("get_today_pixel", "Get today's pixel on Pixela", (*MCPServer).handleGetTodayPixel).WithTitle("Get Today's Pixel").WithMethod(http.MethodGet).WithOutput(PixelOutput{}),
+		NewTool("update_pixel", "Update a pixel on Pixela", (*MCPServer).handleUpdatePixel).WithTitle("Update Pixel").WithMethod(http.MethodPut),
+		NewTool("delete_pixel", "Delete a specific pixel on a specific graph on Pixela", (*MCPServer).handleDeletePixel).WithTitle("Delete Pixel").WithMethod(http.MethodDelete),
+		NewTool("increment_pixel", "Increment the today's pixel on a specific graph on Pixela (for int graphs +1, for float graphs +0.01)", (*MCPServer).handleIncrementPixel).WithTitle("Increment Today's Pixel").WithMethod(http.MethodPut),
+		NewTool("decrement_pixel", "Decrement the today's pixel on a specific graph on Pixela (for int graphs -1, for float graphs -0.01)", (*MCPServer).handleDecrementPixel).WithTitle("Decrement Today's Pixel").WithMethod(http.MethodPut),
+		NewTool("create_webhook", "Create a new webhook on Pixela. The result contains the webhook hash, which is not redacted: anyone who has it can invoke the webhook", (*MCPServer).handleCreateWebhook).WithTitle("Create Webhook").WithMethod(http.MethodPost).WithOutput(WebhookOutput{}),
+		NewTool("get_webhooks", "Get a list of existing webhooks on Pixela. The result contains the webhook hashes, which are not redacted: anyone who has one can invoke the webhook", (*MCPServer).handleGetWebhooks).WithTitle("List Webhooks").WithMethod(http.MethodGet).WithOutput(WebhooksOutput{}),
+		NewTool("invoke_webhook", "Invoke a specific webhook on Pixela", (*MCPServer).handleInvokeWebhook).WithTitle("Invoke Webhook").WithMethod(http.MethodPost),
+		NewTool("delete_webhook", "Delete a specific webhook on Pixela", (*MCPServer).handleDeleteWebhook).WithTitle("Delete Webhook").WithMethod(http.MethodDelete),
+		NewTool("create_channel", "Create a Slack notification channel on Pixela", (*MCPServer).handleCreateChannel).WithTitle("Create Channel").WithMethod(http.MethodPost),
+		NewTool("get_channels", "Get a list of notification channels on Pixela", (*MCPServer).handleGetChannels).WithTitle("List Channels").WithMethod(http.MethodGet).WithOutput(ChannelsOutput{}),
+		NewTool("update_channel", "Update a notification channel on Pixela", (*MCPServer).handleUpdateChannel).WithTitle("Update Channel").WithMethod(http.MethodPut),
+		NewTool("delete_channel", "Delete a notification channel on Pixela", (*MCPServer).handleDeleteChannel).WithTitle("Delete Channel").WithMethod(http.MethodDelete),
+		NewTool("create_notification", "Create a notification rule on a graph on Pixela that notifies a channel when the day's pixel meets a condition (e.g. condition < with threshold 1 and remindBy 21 notifies when nothing was recorded by 21:00)", (*MCPServer).handleCreateNotification).WithTitle("Create Notification").WithMethod(http.MethodPost),
+		NewTool("get_notifications", "Get the notification rules of a graph on Pixela", (*MCPServer).handleGetNotifications).WithTitle("List Notifications").WithMethod(http.MethodGet).WithOutput(NotificationsOutput{}),
+		NewTool("update_notification", "Update a notification rule of a graph on Pixela; settings that are not given keep their current values", (*MCPServer).handleUpdateNotification).WithTitle("Update Notification").WithMethod(http.MethodPut),
+		NewTool("delete_notification", "Delete a notification rule of a graph on Pixela", (*MCPServer).handleDeleteNotification).WithTitle("Delete Notification").WithMethod(http.MethodDelete),
+		NewTool("add_pixel", "Add a value to today's pixel on a specific graph on Pixela", (*MCPServer).handleAddPixel).WithTitle("Add to Today's Pixel").WithMethod(http.MethodPut),
+		NewTool("subtract_pixel", "Subtract a value from today's pixel on a specific graph on Pixela", (*MCPServer).handleSubtractPixel).WithTitle("Subtract from Today's Pixel").WithMethod(http.MethodPut),
+		NewTool("stopwatch", "Start or stop the stopwatch for a specific graph on Pixela", (*MCPServer).handleStopwatch).WithTitle("Start/Stop Stopwatch").WithMethod(http.MethodPost),
+		NewTool("list_profiles", "List the credential profiles configured on the server (tokens are never shown)", (*MCPServer).handleListProfiles).WithTitle("List Profiles").WithOutput(ProfilesOutput{}),
+	)
+	return registry
+}
+
+func (s *MCPServer) handleToolsCall(ctx context.Context, params interface{}) (result map[string]interface{}, mcpErr *MCPError) {
+	// Convert parameters to map
+	paramsMap, ok := params.(map[string]interface{})
 	if !ok {
-		return s.createErrorResult("username parameter is required")
+		return nil, &MCPError{Code: errCodeInvalidParams, Message: "Invalid params: params must be an object"}
 	}
 
-	token, ok := args["token"].(string)
+	// Get tool name
+	toolName, ok := paramsMap["name"].(string)
 	if !ok {
-		return s.createErrorResult("token parameter is required")
+		return nil, &MCPError{Code: errCodeInvalidParams, Message: "Invalid params: tool name is required"}
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
+	// Get tool arguments; they may be omitted for tools without required ones
+	arguments := map[string]interface{}{}
+	if raw, present := paramsMap["arguments"]; present && raw != nil {
+		arguments, ok = raw.(map[string]interface{})
+		if !ok {
+			return nil, &MCPError{Code: errCodeInvalidParams, Message: "Invalid params: arguments must be an object"}
+		}
 	}
 
-	date, ok := args["date"].(string)
+	// Keep the secret arguments out of logs while the call runs, and out of
+	// whatever it returns
+	release := redactor.Track(arguments)
+	defer release()
+	defer func() {
+		if result != nil {
+			redactor.RedactValue(result)
+		}
+		if mcpErr != nil {
+			mcpErr.Message = redactor.Redact(mcpErr.Message)
+			mcpErr.Data = redactor.RedactValue(mcpErr.Data)
+		}
+	}()
+
+	tool, ok := s.tools.Lookup(toolName)
 	if !ok {
-		// If date is not specified, use today's date
-		date = time.Now().Format("20060102")
+		return nil, &MCPError{Code: errCodeInvalidParams, Message: fmt.Sprintf("Unknown tool: %s", toolName)}
+	}
+	if !s.config.Policy.Allows(tool) {
+		return nil, toolNotAllowedError(tool, "the server policy")
 	}
 
-	quantity, ok := args["quantity"].(string)
-	if !ok {
-		return s.createErrorResult("quantity parameter is required")
+	// The profile argument is not part of any tool's own schema; it selects
+	// the credentials and base URL before validation
+	client := pixela.NewClient()
+	if raw, present := arguments["profile"]; present {
+		delete(arguments, "profile")
+		name, ok := raw.(string)
+		if !ok {
+			return nil, invalidParamsError([]ValidationError{{Field: "profile", Message: fmt.Sprintf("expected string, got %s", jsonTypeName(raw))}})
+		}
+		profile, ok := s.config.Profiles.Lookup(name)
+		if !ok {
+			return nil, invalidParamsError([]ValidationError{{Field: "profile", Message: fmt.Sprintf("unknown profile %q", name)}})
+		}
+		if !profile.Policy.Allows(tool) {
+			return nil, toolNotAllowedError(tool, fmt.Sprintf("the policy of profile %q", name))
+		}
+		if profile.BaseURL != "" {
+			client.BaseURL = profile.BaseURL
+		}
+		if tool.UsesCredentials() {
+			applyCredentials(arguments, Credentials{Username: profile.Username, Token: profile.Token})
+		}
+	} else if defaults, ok := s.config.DefaultCredentials(); ok && tool.UsesCredentials() {
+		applyCredentials(arguments, defaults)
 	}
 
-	req := pixela.PostPixelRequest{
-		Date:     date,
-		Quantity: quantity,
+	if errs := tool.InputSchema.Validate(arguments); len(errs) > 0 {
+		return nil, invalidParamsError(errs)
 	}
 
-	resp, err := client.PostPixel(username, token, graphID, req)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to post pixel: %v", err))
+	ctx, trace := pixela.WithRetryTrace(ctx)
+	result = tool.call(s, ctx, client, arguments)
+	if trace.Retries() > 0 {
+		result = s.appendRetrySummary(result, trace)
 	}
+	return result, nil
+}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Pixel was posted successfully (date: %s, quantity: %s)", date, quantity))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to post pixel: %s", resp.Message))
+// applyCredentials fills in username and token when the caller left them
+// out. Explicit arguments win, and the token is never sent on behalf of
+// another user.
+func applyCredentials(arguments map[string]interface{}, creds Credentials) {
+	username, present := arguments["username"]
+	if !present {
+		arguments["username"] = creds.Username
+	} else if username != creds.Username {
+		return
+	}
+	if _, present := arguments["token"]; !present {
+		arguments["token"] = creds.Token
 	}
 }
 
-func (s *MCPServer) handleDeleteUser(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+// appendRetrySummary tells the caller that the result was only obtained after
+// retrying requests Pixela rejected or failed transiently.
+func (s *MCPServer) appendRetrySummary(result map[string]interface{}, trace *pixela.RetryTrace) map[string]interface{} {
+	content, _ := result["content"].([]map[string]interface{})
+	result["content"] = append(content, map[string]interface{}{
+		"type": "text",
+		"text": fmt.Sprintf("Note: retried %d time(s) (%s)", trace.Retries(), trace.Summary()),
+	})
+	return result
+}
+
+func (s *MCPServer) handleCreateUser(ctx context.Context, client *pixela.Client, args CreateUserArgs) map[string]interface{} {
+	req := pixela.CreateUserRequest{
+		Token:               args.Token,
+		Username:            args.Username,
+		AgreeTermsOfService: args.AgreeTermsOfService,
+		NotMinor:            args.NotMinor,
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if _, err := client.CreateUserContext(ctx, req); err != nil {
+		return s.createAPIErrorResult("Failed to create user", err)
 	}
 
-	// Add debug log
-	fmt.Printf("DEBUG: Deleting user '%s' with token '%s'\n", username, token)
+	return s.createSuccessResult(fmt.Sprintf("User '%s' was created successfully", args.Username))
+}
 
-	resp, err := client.DeleteUser(username, token)
-	if err != nil {
-		fmt.Printf("DEBUG: Error deleting user: %v\n", err)
-		return s.createErrorResult(fmt.Sprintf("Failed to delete user: %v", err))
+func (s *MCPServer) handleCreateGraph(ctx context.Context, client *pixela.Client, args CreateGraphArgs) map[string]interface{} {
+	req := pixela.CreateGraphRequest{
+		ID:                  args.GraphID,
+		Name:                args.Name,
+		Unit:                args.Unit,
+		Type:                args.Type,
+		Color:               args.Color,
+		Timezone:            args.Timezone,
+		SelfSufficient:      args.SelfSufficient,
+		IsSecret:            args.IsSecret,
+		PublishOptionalData: args.PublishOptionalData,
+		StartOnMonday:       args.StartOnMonday,
 	}
 
-	fmt.Printf("DEBUG: Pixela API response: %+v\n", resp)
-
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("User '%s' was deleted successfully", username))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to delete user: %s", resp.Message))
+	if _, err := client.CreateGraphContext(ctx, args.Username, args.Token, req); err != nil {
+		return s.createAPIErrorResult("Failed to create graph", err)
 	}
+
+	return s.createSuccessResult(fmt.Sprintf("Graph '%s' was created successfully", args.Name))
 }
 
-func (s *MCPServer) handleUpdateUser(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handlePostPixel(ctx context.Context, client *pixela.Client, args PostPixelArgs) map[string]interface{} {
+	date := args.Date
+	if date == "" {
+		// If date is not specified, use today's date
+		date = time.Now().Format("20060102")
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	req := pixela.PostPixelRequest{
+		Date:         date,
+		Quantity:     args.Quantity,
+		OptionalData: args.OptionalData,
 	}
 
-	newToken, ok := args["newToken"].(string)
-	if !ok {
-		return s.createErrorResult("newToken parameter is required")
+	if _, err := client.PostPixelContext(ctx, args.Username, args.Token, args.GraphID, req); err != nil {
+		return s.createAPIErrorResult("Failed to post pixel", err)
 	}
 
-	// thanksCode is optional
-	thanksCode, _ := args["thanksCode"].(string)
+	return s.createSuccessResult(fmt.Sprintf("Pixel was posted successfully (date: %s, quantity: %s)", date, args.Quantity))
+}
 
-	req := pixela.UpdateUserRequest{
-		NewToken:   newToken,
-		ThanksCode: thanksCode,
+func (s *MCPServer) handleDeleteUser(ctx context.Context, client *pixela.Client, args DeleteUserArgs) map[string]interface{} {
+	if result := s.confirmDestruction(ctx, "username", args.Username, args.Confirm, func(ctx context.Context) string {
+		return describeUserDeletion(ctx, client, args.Credentials)
+	}); result != nil {
+		return result
 	}
 
-	resp, err := client.UpdateUser(username, token, req)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to update user: %v", err))
+	if _, err := client.DeleteUserContext(ctx, args.Username, args.Token); err != nil {
+		return s.createAPIErrorResult("Failed to delete user", err)
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("User '%s' information was updated successfully", username))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to update user: %s", resp.Message))
-	}
+	return s.createSuccessResult(fmt.Sprintf("User '%s' was deleted successfully", args.Username))
 }
 
-func (s *MCPServer) handleUpdateUserProfile(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleUpdateUser(ctx context.Context, client *pixela.Client, args UpdateUserArgs) map[string]interface{} {
+	req := pixela.UpdateUserRequest{
+		NewToken:   args.NewToken,
+		ThanksCode: args.ThanksCode,
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if _, err := client.UpdateUserContext(ctx, args.Username, args.Token, req); err != nil {
+		return s.createAPIErrorResult("Failed to update user", err)
 	}
 
-	// All profile update parameters are optional
-	displayName, _ := args["displayName"].(string)
-	profileURL, _ := args["profileURL"].(string)
-	description, _ := args["description"].(string)
-	avatarURL, _ := args["avatarURL"].(string)
-	twitter, _ := args["twitter"].(string)
-	github, _ := args["github"].(string)
-	website, _ := args["website"].(string)
+	return s.createSuccessResult(fmt.Sprintf("User '%s' information was updated successfully", args.Username))
+}
 
+func (s *MCPServer) handleUpdateUserProfile(ctx context.Context, client *pixela.Client, args UpdateUserProfileArgs) map[string]interface{} {
 	req := pixela.UpdateUserProfileRequest{
-		DisplayName: displayName,
-		ProfileURL:  profileURL,
-		Description: description,
-		AvatarURL:   avatarURL,
-		Twitter:     twitter,
-		GitHub:      github,
-		Website:     website,
+		DisplayName:       args.DisplayName,
+		GravatarIconEmail: args.GravatarIconEmail,
+		Title:             args.Title,
+		Timezone:          args.Timezone,
+		AboutURL:          args.AboutURL,
+		ContributeURLs:    args.ContributeURLs,
+		PixelaGraph:       args.PixelaGraph,
 	}
 
-	resp, err := client.UpdateUserProfile(username, token, req)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to update user profile: %v", err))
+	if _, err := client.UpdateUserProfileContext(ctx, args.Username, args.Token, req); err != nil {
+		return s.createAPIErrorResult("Failed to update user profile", err)
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("User '%s' profile was updated successfully", username))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to update user profile: %s", resp.Message))
-	}
+	return s.createSuccessResult(fmt.Sprintf("User '%s' profile was updated successfully", args.Username))
 }
 
-func (s *MCPServer) handleGetGraphs(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+// handleGetUserProfile reads the profile from the public profile page, as
+// Pixela has no API that returns it.
+func (s *MCPServer) handleGetUserProfile(ctx context.Context, client *pixela.Client, args GetUserProfileArgs) map[string]interface{} {
+	pageURL := client.ProfilePageURL(args.Username)
+	page, err := client.GetProfilePageContext(ctx, args.Username)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get user profile", err)
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	var host string
+	if u, err := url.Parse(pageURL); err == nil {
+		host = u.Hostname()
 	}
+	profile := profileFromPage(page, host, args.Username)
+	profile.URL = pageURL
+	return s.createSuccessResult(fmt.Sprintf("Profile of '%s' retrieved: %s", args.Username, pageURL), profile)
+}
 
-	resp, err := client.GetGraphs(username, token)
+func (s *MCPServer) handleGetGraphs(ctx context.Context, client *pixela.Client, args Credentials) map[string]interface{} {
+	resp, err := client.GetGraphsContext(ctx, args.Username, args.Token)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get graph definitions: %v", err))
+		return s.createAPIErrorResult("Failed to get graph definitions", err)
+	}
+
+	output := GraphsOutput{Graphs: make([]GraphDefinitionOutput, 0, len(resp.Graphs))}
+	for _, graph := range resp.Graphs {
+		output.Graphs = append(output.Graphs, newGraphDefinitionOutput(graph))
 	}
 
 	if len(resp.Graphs) == 0 {
-		return s.createSuccessResult(fmt.Sprintf("No graphs found for user '%s'", username))
+		return s.createSuccessResult(fmt.Sprintf("No graphs found for user '%s'", args.Username), output)
 	}
 
 	// Format graph list for return
@@ -374,694 +690,612 @@ func (s *MCPServer) handleGetGraphs(client *pixela.Client, args map[string]inter
 	}
 
 	message := fmt.Sprintf("Graph list for user '%s' (%d items):\n%s",
-		username, len(resp.Graphs), strings.Join(graphList, "\n"))
+		args.Username, len(resp.Graphs), strings.Join(graphList, "\n"))
 
-	return s.createSuccessResult(message)
+	return s.createSuccessResult(message, output)
 }
 
-func (s *MCPServer) handleGetGraphDefinition(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func newGraphDefinitionOutput(graph pixela.GraphDefinition) GraphDefinitionOutput {
+	return GraphDefinitionOutput{
+		ID:                  graph.ID,
+		Name:                graph.Name,
+		Unit:                graph.Unit,
+		Type:                graph.Type,
+		Color:               graph.Color,
+		Timezone:            graph.Timezone,
+		SelfSufficient:      graph.SelfSufficient,
+		IsSecret:            bool(graph.IsSecret),
+		PublishOptionalData: bool(graph.PublishOptionalData),
+		StartOnMonday:       bool(graph.StartOnMonday),
 	}
+}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+func (s *MCPServer) handleGetGraphDefinition(ctx context.Context, client *pixela.Client, args GraphArgs) map[string]interface{} {
+	graph, err := client.GetGraphDefinitionContext(ctx, args.Username, args.Token, args.GraphID)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get graph definition", err)
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("Graph definition retrieved: %s", graph.Name), newGraphDefinitionOutput(*graph))
+}
 
-	graph, err := client.GetGraphDefinition(username, token, graphID)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get graph definition: %v", err))
+func (s *MCPServer) handleUpdateGraph(ctx context.Context, client *pixela.Client, args UpdateGraphArgs) map[string]interface{} {
+	req := pixela.UpdateGraphRequest{
+		Name:                args.Name,
+		Unit:                args.Unit,
+		Color:               args.Color,
+		Timezone:            args.Timezone,
+		PurgeCacheURLs:      args.PurgeCacheURLs,
+		SelfSufficient:      args.SelfSufficient,
+		IsSecret:            args.IsSecret,
+		PublishOptionalData: args.PublishOptionalData,
+		StartOnMonday:       args.StartOnMonday,
 	}
 
-	graphData := map[string]interface{}{
-		"id":                  graph.ID,
-		"name":                graph.Name,
-		"unit":                graph.Unit,
-		"type":                graph.Type,
-		"color":               graph.Color,
-		"timezone":            graph.Timezone,
-		"selfSufficient":      bool(graph.SelfSufficient),
-		"isSecret":            bool(graph.IsSecret),
-		"publishOptionalData": bool(graph.PublishOptionalData),
+	if _, err := client.UpdateGraphContext(ctx, args.Username, args.Token, args.GraphID, req); err != nil {
+		return s.createAPIErrorResult("Failed to update graph", err)
 	}
 
-	return s.createSuccessResult(fmt.Sprintf("Graph definition retrieved: %s", graph.Name), graphData)
+	return s.createSuccessResult(fmt.Sprintf("Graph '%s' was updated successfully", args.GraphID))
 }
 
-func (s *MCPServer) handleUpdateGraph(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleDeleteGraph(ctx context.Context, client *pixela.Client, args DeleteGraphArgs) map[string]interface{} {
+	if result := s.confirmDestruction(ctx, "graphID", args.GraphID, args.Confirm, func(ctx context.Context) string {
+		return describeGraphDeletion(ctx, client, args.GraphArgs)
+	}); result != nil {
+		return result
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if _, err := client.DeleteGraphContext(ctx, args.Username, args.Token, args.GraphID); err != nil {
+		return s.createAPIErrorResult("Failed to delete graph", err)
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
-
-	req := pixela.UpdateGraphRequest{}
+	return s.createSuccessResult(fmt.Sprintf("Graph '%s' was deleted successfully", args.GraphID))
+}
 
-	// Set optional parameters
-	if name, ok := args["name"].(string); ok {
-		req.Name = name
-	}
-	if unit, ok := args["unit"].(string); ok {
-		req.Unit = unit
-	}
-	if color, ok := args["color"].(string); ok {
-		req.Color = color
-	}
-	if timezone, ok := args["timezone"].(string); ok {
-		req.Timezone = timezone
-	}
-	if selfSufficient, ok := args["selfSufficient"].(string); ok {
-		req.SelfSufficient = selfSufficient
+func (s *MCPServer) handleGetPixels(ctx context.Context, client *pixela.Client, args GetPixelsArgs) map[string]interface{} {
+	var from, to, withBody *string
+	if args.From != "" {
+		from = &args.From
 	}
-	if isSecret, ok := args["isSecret"].(string); ok {
-		req.IsSecret = isSecret
+	if args.To != "" {
+		to = &args.To
 	}
-	if publishOptionalData, ok := args["publishOptionalData"].(string); ok {
-		req.PublishOptionalData = publishOptionalData
+	if args.WithBody {
+		v := "true"
+		withBody = &v
 	}
 
-	resp, err := client.UpdateGraph(username, token, graphID, req)
+	pixels, err := client.GetPixelsContext(ctx, args.Username, args.Token, args.GraphID, from, to, withBody)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to update graph: %v", err))
+		return s.createAPIErrorResult("Failed to get pixel list", err)
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Graph '%s' was updated successfully", graphID))
+	// If withBody is true, return detailed array, otherwise return date array
+	output := PixelsOutput{Pixels: []PixelOutput{}}
+	if args.WithBody {
+		for _, detail := range pixels.Pixels.Details {
+			output.Pixels = append(output.Pixels, PixelOutput{
+				Date:         detail.Date,
+				Quantity:     detail.Quantity,
+				OptionalData: detail.OptionalData,
+			})
+		}
 	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to update graph: %s", resp.Message))
-	}
-}
-
-func (s *MCPServer) handleDeleteGraph(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+		for _, date := range pixels.Pixels.Dates {
+			output.Pixels = append(output.Pixels, PixelOutput{Date: date})
+		}
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if len(output.Pixels) == 0 {
+		return s.createSuccessResult(fmt.Sprintf("No pixels found for graph '%s'", args.GraphID), output)
 	}
-
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
+	if args.WithBody {
+		return s.createSuccessResult(fmt.Sprintf("Retrieved pixel details list for graph '%s' (%d items)", args.GraphID, len(output.Pixels)), output)
 	}
+	return s.createSuccessResult(fmt.Sprintf("Retrieved pixel list for graph '%s' (%d items)", args.GraphID, len(output.Pixels)), output)
+}
 
-	resp, err := client.DeleteGraph(username, token, graphID)
+func (s *MCPServer) handleGetGraphStats(ctx context.Context, client *pixela.Client, args GraphArgs) map[string]interface{} {
+	stats, err := client.GetGraphStatsContext(ctx, args.Username, args.Token, args.GraphID)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to delete graph: %v", err))
+		return s.createAPIErrorResult("Failed to get graph statistics", err)
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Graph '%s' was deleted successfully", graphID))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to delete graph: %s", resp.Message))
+	statsData := GraphStatsOutput{
+		TotalPixelsCount:  stats.TotalPixelsCount,
+		MaxQuantity:       stats.MaxQuantity.String(),
+		MinQuantity:       stats.MinQuantity.String(),
+		MaxDate:           stats.MaxDate,
+		MinDate:           stats.MinDate,
+		TotalQuantity:     stats.TotalQuantity.String(),
+		AvgQuantity:       stats.AvgQuantity.String(),
+		TodaysQuantity:    stats.TodaysQuantity.String(),
+		YesterdayQuantity: stats.YesterdayQuantity.String(),
 	}
+
+	return s.createSuccessResult(fmt.Sprintf("Graph '%s' statistics retrieved", args.GraphID), statsData)
 }
 
-func (s *MCPServer) handleGetPixels(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+// handleGetGraphSVG returns the SVG as an embedded resource whose URI is the
+// shareable URL of the same rendering.
+func (s *MCPServer) handleGetGraphSVG(ctx context.Context, client *pixela.Client, args GetGraphSVGArgs) map[string]interface{} {
+	opts := args.renderOptions()
+	svg, err := client.GetGraphWithOptionsContext(ctx, args.Username, args.Token, args.GraphID, opts)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get graph SVG", err)
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	graphURL := client.GraphURL(args.Username, args.GraphID, opts)
+	return map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": fmt.Sprintf("Graph '%s' rendered as SVG. Shareable URL: %s", args.GraphID, graphURL),
+			},
+			{
+				"type": "resource",
+				"resource": map[string]interface{}{
+					"uri":      graphURL,
+					"mimeType": "image/svg+xml",
+					"text":     svg,
+				},
+			},
+		},
 	}
+}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+func (s *MCPServer) handleGetGraphPage(ctx context.Context, client *pixela.Client, args GetGraphPageArgs) map[string]interface{} {
+	pageURL := client.GraphPageURL(args.Username, args.GraphID, args.Mode)
+	return s.pageResult(fmt.Sprintf("Graph page of '%s'", args.GraphID), pageURL, args.Fetch, func() (string, error) {
+		return client.GetGraphPageContext(ctx, args.Username, args.GraphID, args.Mode)
+	})
+}
 
-	var from, to, withBody *string
-	if v, ok := args["from"].(string); ok {
-		from = &v
-	}
-	if v, ok := args["to"].(string); ok {
-		to = &v
-	}
-	if v, ok := args["withBody"].(string); ok {
-		withBody = &v
+func (s *MCPServer) handleGetProfilePage(ctx context.Context, client *pixela.Client, args GetProfilePageArgs) map[string]interface{} {
+	pageURL := client.ProfilePageURL(args.Username)
+	return s.pageResult(fmt.Sprintf("Profile page of '%s'", args.Username), pageURL, args.Fetch, func() (string, error) {
+		return client.GetProfilePageContext(ctx, args.Username)
+	})
+}
+
+// pageResult returns the URL of a page and, if fetch is set, the summary and
+// outbound links of the page returned by get.
+func (s *MCPServer) pageResult(name, pageURL string, fetch bool, get func() (string, error)) map[string]interface{} {
+	output := PageOutput{URL: pageURL}
+	if !fetch {
+		return s.createSuccessResult(fmt.Sprintf("%s: %s", name, pageURL), output)
 	}
 
-	pixels, err := client.GetPixels(username, token, graphID, from, to, withBody)
+	page, err := get()
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get pixel list: %v", err))
+		return s.createAPIErrorResult("Failed to fetch the page", err)
 	}
-
-	// If withBody is true, return detailed array, otherwise return date array
-	if withBody != nil && *withBody == "true" {
-		if len(pixels.Pixels.Details) == 0 {
-			return s.createSuccessResult(fmt.Sprintf("No pixels found for graph '%s'", graphID))
-		}
-		var pixelList []map[string]interface{}
-		for _, detail := range pixels.Pixels.Details {
-			pixelData := map[string]interface{}{
-				"date":     detail.Date,
-				"quantity": detail.Quantity,
-			}
-			if detail.OptionalData != "" {
-				pixelData["optionalData"] = detail.OptionalData
-			}
-			pixelList = append(pixelList, pixelData)
-		}
-		return s.createSuccessResult(fmt.Sprintf("Retrieved pixel details list for graph '%s' (%d items)", graphID, len(pixelList)), pixelList)
-	} else {
-		if len(pixels.Pixels.Dates) == 0 {
-			return s.createSuccessResult(fmt.Sprintf("No pixels found for graph '%s'", graphID))
-		}
-		var pixelList []map[string]interface{}
-		for _, date := range pixels.Pixels.Dates {
-			pixelData := map[string]interface{}{
-				"date": date,
-			}
-			pixelList = append(pixelList, pixelData)
-		}
-		return s.createSuccessResult(fmt.Sprintf("Retrieved pixel list for graph '%s' (%d items)", graphID, len(pixelList)), pixelList)
+	output.Title, output.Summary = summarizePage(page)
+	text := fmt.Sprintf("%s: %s\n\n%s", name, pageURL, output.Summary)
+	if u, err := url.Parse(pageURL); err == nil {
+		output.Links = pageLinks(page, u.Hostname())
 	}
+	if len(output.Links) > 0 {
+		text += "\n\nLinks:\n" + strings.Join(output.Links, "\n")
+	}
+	return s.createSuccessResult(text, output)
 }
 
-func (s *MCPServer) handleGetGraphStats(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+// handleRenderGraphImage rasterizes the graph SVG for clients that only
+// display PNG images.
+func (s *MCPServer) handleRenderGraphImage(ctx context.Context, client *pixela.Client, args RenderGraphImageArgs) map[string]interface{} {
+	scale := args.Scale
+	if scale == 0 {
+		scale = defaultImageScale
 	}
-
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if scale < 0 || scale > maxImageScale {
+		return s.createErrorResult(fmt.Sprintf("scale must be greater than 0 and at most %d", maxImageScale))
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
+	opts := args.renderOptions()
+	svg, err := client.GetGraphWithOptionsContext(ctx, args.Username, args.Token, args.GraphID, opts)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get graph SVG", err)
 	}
 
-	stats, err := client.GetGraphStats(username, token, graphID)
+	img, err := raster.Rasterize([]byte(svg), scale)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get graph statistics: %v", err))
+		return s.toolErrorResult(fmt.Sprintf("Failed to render graph image: %v", err), ToolError{Code: "render_failed"})
 	}
-
-	statsData := map[string]interface{}{
-		"totalPixelsCount":  stats.TotalPixelsCount,
-		"maxQuantity":       stats.MaxQuantity.String(),
-		"minQuantity":       stats.MinQuantity.String(),
-		"maxDate":           stats.MaxDate,
-		"minDate":           stats.MinDate,
-		"totalQuantity":     stats.TotalQuantity.String(),
-		"avgQuantity":       stats.AvgQuantity.String(),
-		"todaysQuantity":    stats.TodaysQuantity.String(),
-		"yesterdayQuantity": stats.YesterdayQuantity.String(),
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return s.toolErrorResult(fmt.Sprintf("Failed to encode graph image: %v", err), ToolError{Code: "render_failed"})
 	}
 
-	return s.createSuccessResult(fmt.Sprintf("Graph '%s' statistics retrieved", graphID), statsData)
+	bounds := img.Bounds()
+	return map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": fmt.Sprintf("Graph '%s' rendered as a %dx%d PNG. Shareable SVG URL: %s",
+					args.GraphID, bounds.Dx(), bounds.Dy(), client.GraphURL(args.Username, args.GraphID, opts)),
+			},
+			{
+				"type":     "image",
+				"data":     base64.StdEncoding.EncodeToString(buf.Bytes()),
+				"mimeType": "image/png",
+			},
+		},
+	}
 }
 
-func (s *MCPServer) handleBatchPostPixels(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
-	pixelsRaw, ok := args["pixels"].([]interface{})
-	if !ok || len(pixelsRaw) == 0 {
+func (s *MCPServer) handleBatchPostPixels(ctx context.Context, client *pixela.Client, args BatchPostPixelsArgs) map[string]interface{} {
+	if len(args.Pixels) == 0 {
 		return s.createErrorResult("pixels array parameter is required")
 	}
 	var pixels []pixela.PostPixelRequest
-	for _, p := range pixelsRaw {
-		m, ok := p.(map[string]interface{})
-		if !ok {
-			return s.createErrorResult("elements in pixels array are invalid")
-		}
-		date, _ := m["date"].(string)
-		quantity, _ := m["quantity"].(string)
-		optionalData, _ := m["optionalData"].(string)
-		if date == "" || quantity == "" {
+	for _, p := range args.Pixels {
+		if p.Date == "" || p.Quantity == "" {
 			return s.createErrorResult("each element in pixels array requires date and quantity")
 		}
 		pixels = append(pixels, pixela.PostPixelRequest{
-			Date:         date,
-			Quantity:     quantity,
-			OptionalData: optionalData,
+			Date:         p.Date,
+			Quantity:     p.Quantity,
+			OptionalData: p.OptionalData,
 		})
 	}
-	resp, err := client.BatchPostPixels(username, token, graphID, pixels)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to batch post pixels: %v", err))
-	}
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("%d pixels were successfully registered", len(pixels)))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to batch post pixels: %s", resp.Message))
+	if _, err := client.BatchPostPixelsContext(ctx, args.Username, args.Token, args.GraphID, pixels); err != nil {
+		return s.createAPIErrorResult("Failed to batch post pixels", err)
 	}
-}
 
-func (s *MCPServer) handleGetPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
-	date, ok := args["date"].(string)
-	if !ok {
-		return s.createErrorResult("date parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("%d pixels were successfully registered", len(pixels)))
+}
 
-	pixel, err := client.GetPixel(username, token, graphID, date)
+func (s *MCPServer) handleGetPixel(ctx context.Context, client *pixela.Client, args PixelArgs) map[string]interface{} {
+	pixel, err := client.GetPixelContext(ctx, args.Username, args.Token, args.GraphID, args.Date)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get pixel: %v", err))
+		return s.createAPIErrorResult("Failed to get pixel", err)
 	}
 
-	pixelData := map[string]interface{}{
-		"date":     pixel.Date,
-		"quantity": pixel.Quantity,
-	}
-	if pixel.OptionalData != "" {
-		pixelData["optionalData"] = pixel.OptionalData
+	pixelData := PixelOutput{
+		Date:         pixel.Date,
+		Quantity:     pixel.Quantity,
+		OptionalData: pixel.OptionalData,
 	}
 
-	return s.createSuccessResult(fmt.Sprintf("Pixel for date %s retrieved", date), pixelData)
+	return s.createSuccessResult(fmt.Sprintf("Pixel for date %s retrieved", args.Date), pixelData)
 }
 
-func (s *MCPServer) handleGetLatestPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
-
-	pixel, err := client.GetLatestPixel(username, token, graphID)
+func (s *MCPServer) handleGetLatestPixel(ctx context.Context, client *pixela.Client, args GraphArgs) map[string]interface{} {
+	pixel, err := client.GetLatestPixelContext(ctx, args.Username, args.Token, args.GraphID)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get latest pixel: %v", err))
+		return s.createAPIErrorResult("Failed to get latest pixel", err)
 	}
 
-	pixelData := map[string]interface{}{
-		"date":     pixel.Date,
-		"quantity": pixel.Quantity,
-	}
-	if pixel.OptionalData != "" {
-		pixelData["optionalData"] = pixel.OptionalData
+	pixelData := PixelOutput{
+		Date:         pixel.Date,
+		Quantity:     pixel.Quantity,
+		OptionalData: pixel.OptionalData,
 	}
 
-	return s.createSuccessResult(fmt.Sprintf("Latest pixel for graph '%s' (date: %s) retrieved", graphID, pixel.Date), pixelData)
+	return s.createSuccessResult(fmt.Sprintf("Latest pixel for graph '%s' (date: %s) retrieved", args.GraphID, pixel.Date), pixelData)
 }
 
-func (s *MCPServer) handleGetTodayPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
+func (s *MCPServer) handleGetTodayPixel(ctx context.Context, client *pixela.Client, args GetTodayPixelArgs) map[string]interface{} {
+	pixel, err := client.GetTodayPixelContext(ctx, args.Username, args.Token, args.GraphID, args.ReturnEmpty)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get today's pixel", err)
 	}
 
-	var returnEmpty *bool
-	if v, ok := args["returnEmpty"].(string); ok {
-		if v == "true" {
-			trueVal := true
-			returnEmpty = &trueVal
-		} else if v == "false" {
-			falseVal := false
-			returnEmpty = &falseVal
-		}
+	pixelData := PixelOutput{
+		Date:         pixel.Date,
+		Quantity:     pixel.Quantity,
+		OptionalData: pixel.OptionalData,
 	}
 
-	pixel, err := client.GetTodayPixel(username, token, graphID, returnEmpty)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get today's pixel: %v", err))
-	}
+	return s.createSuccessResult(fmt.Sprintf("Today's pixel for graph '%s' (date: %s) retrieved", args.GraphID, pixel.Date), pixelData)
+}
 
-	pixelData := map[string]interface{}{
-		"date":     pixel.Date,
-		"quantity": pixel.Quantity,
+func (s *MCPServer) handleUpdatePixel(ctx context.Context, client *pixela.Client, args UpdatePixelArgs) map[string]interface{} {
+	req := pixela.UpdatePixelRequest{
+		Quantity:     args.Quantity,
+		OptionalData: args.OptionalData,
 	}
-	if pixel.OptionalData != "" {
-		pixelData["optionalData"] = pixel.OptionalData
+
+	if _, err := client.UpdatePixelContext(ctx, args.Username, args.Token, args.GraphID, args.Date, req); err != nil {
+		return s.createAPIErrorResult("Failed to update pixel", err)
 	}
 
-	return s.createSuccessResult(fmt.Sprintf("Today's pixel for graph '%s' (date: %s) retrieved", graphID, pixel.Date), pixelData)
+	return s.createSuccessResult(fmt.Sprintf("Pixel (%s) updated successfully", args.Date))
 }
 
-func (s *MCPServer) handleUpdatePixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleDeletePixel(ctx context.Context, client *pixela.Client, args DeletePixelArgs) map[string]interface{} {
+	if result := s.confirmDestruction(ctx, "date", args.Date, args.Confirm, func(ctx context.Context) string {
+		return describePixelDeletion(ctx, client, args.PixelArgs)
+	}); result != nil {
+		return result
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if _, err := client.DeletePixelContext(ctx, args.Username, args.Token, args.GraphID, args.Date); err != nil {
+		return s.createAPIErrorResult("Failed to delete pixel", err)
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("Pixel (%s) deleted successfully", args.Date))
+}
 
-	date, ok := args["date"].(string)
-	if !ok {
-		return s.createErrorResult("date parameter is required")
+func (s *MCPServer) handleIncrementPixel(ctx context.Context, client *pixela.Client, args GraphArgs) map[string]interface{} {
+	if _, err := client.IncrementPixelContext(ctx, args.Username, args.Token, args.GraphID); err != nil {
+		return s.createAPIErrorResult("Failed to increment pixel", err)
 	}
 
-	quantity, ok := args["quantity"].(string)
-	if !ok {
-		return s.createErrorResult("quantity parameter is required")
-	}
+	return s.createSuccessResult("Today's pixel incremented successfully")
+}
 
-	req := pixela.UpdatePixelRequest{
-		Quantity: quantity,
+func (s *MCPServer) handleDecrementPixel(ctx context.Context, client *pixela.Client, args GraphArgs) map[string]interface{} {
+	if _, err := client.DecrementPixelContext(ctx, args.Username, args.Token, args.GraphID); err != nil {
+		return s.createAPIErrorResult("Failed to decrement pixel", err)
 	}
 
-	if optionalData, ok := args["optionalData"].(string); ok && optionalData != "" {
-		req.OptionalData = optionalData
+	return s.createSuccessResult("Today's pixel decremented successfully")
+}
+
+func (s *MCPServer) handleCreateWebhook(ctx context.Context, client *pixela.Client, args CreateWebhookArgs) map[string]interface{} {
+	req := pixela.CreateWebhookRequest{
+		GraphID:  args.GraphID,
+		Type:     args.Type,
+		Quantity: args.Quantity,
 	}
 
-	resp, err := client.UpdatePixel(username, token, graphID, date, req)
+	webhook, err := client.CreateWebhookContext(ctx, args.Username, args.Token, req)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to update pixel: %v", err))
+		return s.createAPIErrorResult("Failed to create webhook", err)
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Pixel (%s) updated successfully", date))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to update pixel: %s", resp.Message))
-	}
+	return s.createSuccessResult(fmt.Sprintf("Webhook created successfully (webhookHash: %s)", webhook.WebhookHash), newWebhookOutput(*webhook))
 }
 
-func (s *MCPServer) handleDeletePixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleGetWebhooks(ctx context.Context, client *pixela.Client, args Credentials) map[string]interface{} {
+	webhooksResponse, err := client.GetWebhooksContext(ctx, args.Username, args.Token)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get webhook list", err)
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	webhooksData := WebhooksOutput{Webhooks: make([]WebhookOutput, 0, len(webhooksResponse.Webhooks))}
+	for _, webhook := range webhooksResponse.Webhooks {
+		webhooksData.Webhooks = append(webhooksData.Webhooks, newWebhookOutput(webhook))
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("%d webhooks retrieved", len(webhooksResponse.Webhooks)), webhooksData)
+}
 
-	date, ok := args["date"].(string)
-	if !ok {
-		return s.createErrorResult("date parameter is required")
+func newWebhookOutput(webhook pixela.Webhook) WebhookOutput {
+	return WebhookOutput{
+		WebhookHash: webhook.WebhookHash,
+		GraphID:     webhook.GraphID,
+		Type:        webhook.Type,
+		Quantity:    webhook.Quantity,
 	}
+}
 
-	resp, err := client.DeletePixel(username, token, graphID, date)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to delete pixel: %v", err))
+func (s *MCPServer) handleInvokeWebhook(ctx context.Context, client *pixela.Client, args InvokeWebhookArgs) map[string]interface{} {
+	if _, err := client.InvokeWebhookContext(ctx, args.Username, args.WebhookHash); err != nil {
+		return s.createAPIErrorResult("Failed to invoke webhook", err)
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Pixel (%s) deleted successfully", date))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to delete pixel: %s", resp.Message))
-	}
+	return s.createSuccessResult(fmt.Sprintf("Webhook '%s' executed successfully", args.WebhookHash))
 }
 
-func (s *MCPServer) handleIncrementPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleDeleteWebhook(ctx context.Context, client *pixela.Client, args DeleteWebhookArgs) map[string]interface{} {
+	if result := s.confirmDestruction(ctx, "webhookHash", args.WebhookHash, args.Confirm, func(ctx context.Context) string {
+		return describeWebhookDeletion(ctx, client, args)
+	}); result != nil {
+		return result
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if _, err := client.DeleteWebhookContext(ctx, args.Username, args.Token, args.WebhookHash); err != nil {
+		return s.createAPIErrorResult("Failed to delete webhook", err)
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("Webhook '%s' deleted successfully", args.WebhookHash))
+}
 
-	resp, err := client.IncrementPixel(username, token, graphID)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to increment pixel: %v", err))
+func (s *MCPServer) handleCreateChannel(ctx context.Context, client *pixela.Client, args ChannelSettingsArgs) map[string]interface{} {
+	req := pixela.CreateChannelRequest{
+		ID:     args.ChannelID,
+		Name:   args.Name,
+		Type:   args.channelType(),
+		Detail: args.detail(),
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult("Today's pixel incremented successfully")
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to increment pixel: %s", resp.Message))
+	if _, err := client.CreateChannelContext(ctx, args.Username, args.Token, req); err != nil {
+		return s.createAPIErrorResult("Failed to create channel", err)
 	}
+
+	return s.createSuccessResult(fmt.Sprintf("Channel '%s' created successfully", args.ChannelID))
 }
 
-func (s *MCPServer) handleDecrementPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleGetChannels(ctx context.Context, client *pixela.Client, args Credentials) map[string]interface{} {
+	channelsResponse, err := client.GetChannelsContext(ctx, args.Username, args.Token)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get channel list", err)
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	channelsData := ChannelsOutput{Channels: make([]ChannelOutput, 0, len(channelsResponse.Channels))}
+	for _, channel := range channelsResponse.Channels {
+		channelsData.Channels = append(channelsData.Channels, ChannelOutput{
+			ID:          channel.ID,
+			Name:        channel.Name,
+			Type:        channel.Type,
+			UserName:    channel.Detail.UserName,
+			ChannelName: channel.Detail.ChannelName,
+		})
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("%d channels retrieved", len(channelsResponse.Channels)), channelsData)
+}
 
-	resp, err := client.DecrementPixel(username, token, graphID)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to decrement pixel: %v", err))
+func (s *MCPServer) handleUpdateChannel(ctx context.Context, client *pixela.Client, args ChannelSettingsArgs) map[string]interface{} {
+	req := pixela.UpdateChannelRequest{
+		Name:   args.Name,
+		Type:   args.channelType(),
+		Detail: args.detail(),
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult("Today's pixel decremented successfully")
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to decrement pixel: %s", resp.Message))
+	if _, err := client.UpdateChannelContext(ctx, args.Username, args.Token, args.ChannelID, req); err != nil {
+		return s.createAPIErrorResult("Failed to update channel", err)
 	}
+
+	return s.createSuccessResult(fmt.Sprintf("Channel '%s' updated successfully", args.ChannelID))
 }
 
-func (s *MCPServer) handleCreateWebhook(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleDeleteChannel(ctx context.Context, client *pixela.Client, args DeleteChannelArgs) map[string]interface{} {
+	if result := s.confirmDestruction(ctx, "channelID", args.ChannelID, args.Confirm, func(ctx context.Context) string {
+		return describeChannelDeletion(ctx, client, args.ChannelArgs)
+	}); result != nil {
+		return result
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if _, err := client.DeleteChannelContext(ctx, args.Username, args.Token, args.ChannelID); err != nil {
+		return s.createAPIErrorResult("Failed to delete channel", err)
 	}
 
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("Channel '%s' deleted successfully", args.ChannelID))
+}
 
-	webhookType, ok := args["type"].(string)
-	if !ok {
-		return s.createErrorResult("type parameter is required")
+func (a ChannelSettingsArgs) channelType() string {
+	if a.Type == "" {
+		return "slack"
 	}
+	return a.Type
+}
 
-	req := pixela.CreateWebhookRequest{
-		GraphID: graphID,
-		Type:    webhookType,
+func (a ChannelSettingsArgs) detail() pixela.ChannelDetail {
+	return pixela.ChannelDetail{
+		URL:         a.URL,
+		UserName:    a.UserName,
+		ChannelName: a.ChannelName,
 	}
+}
 
-	if quantity, ok := args["quantity"].(string); ok && quantity != "" {
-		req.Quantity = quantity
+func (s *MCPServer) handleCreateNotification(ctx context.Context, client *pixela.Client, args CreateNotificationArgs) map[string]interface{} {
+	target := args.Target
+	if target == "" {
+		target = "quantity"
 	}
-
-	webhook, err := client.CreateWebhook(username, token, req)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to create webhook: %v", err))
+	req := pixela.CreateNotificationRequest{
+		ID:        args.NotificationID,
+		Name:      args.Name,
+		Target:    target,
+		Condition: args.Condition,
+		Threshold: args.Threshold,
+		RemindBy:  args.RemindBy,
+		ChannelID: args.ChannelID,
 	}
 
-	webhookData := map[string]interface{}{
-		"webhookHash": webhook.WebhookHash,
-		"graphID":     webhook.GraphID,
-		"type":        webhook.Type,
-	}
-	if webhook.Quantity != "" {
-		webhookData["quantity"] = webhook.Quantity
+	if _, err := client.CreateNotificationContext(ctx, args.Username, args.Token, args.GraphID, req); err != nil {
+		return s.createAPIErrorResult("Failed to create notification", err)
 	}
 
-	return s.createSuccessResult(fmt.Sprintf("Webhook created successfully (webhookHash: %s)", webhook.WebhookHash), webhookData)
+	return s.createSuccessResult(fmt.Sprintf("Notification '%s' created on graph '%s'", args.NotificationID, args.GraphID))
 }
 
-func (s *MCPServer) handleGetWebhooks(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+func (s *MCPServer) handleGetNotifications(ctx context.Context, client *pixela.Client, args GraphArgs) map[string]interface{} {
+	notificationsResponse, err := client.GetNotificationsContext(ctx, args.Username, args.Token, args.GraphID)
+	if err != nil {
+		return s.createAPIErrorResult("Failed to get notification list", err)
+	}
+
+	notificationsData := NotificationsOutput{Notifications: make([]NotificationOutput, 0, len(notificationsResponse.Notifications))}
+	for _, notification := range notificationsResponse.Notifications {
+		notificationsData.Notifications = append(notificationsData.Notifications, NotificationOutput{
+			ID:        notification.ID,
+			Name:      notification.Name,
+			Target:    notification.Target,
+			Condition: notification.Condition,
+			Threshold: notification.Threshold.String(),
+			RemindBy:  notification.RemindBy,
+			ChannelID: notification.ChannelID,
+		})
 	}
 
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
+	return s.createSuccessResult(fmt.Sprintf("%d notifications retrieved for graph '%s'", len(notificationsResponse.Notifications), args.GraphID), notificationsData)
+}
 
-	webhooksResponse, err := client.GetWebhooks(username, token)
+// handleUpdateNotification merges the given settings into the current rule,
+// as Pixela replaces the whole rule on update.
+func (s *MCPServer) handleUpdateNotification(ctx context.Context, client *pixela.Client, args UpdateNotificationArgs) map[string]interface{} {
+	notificationsResponse, err := client.GetNotificationsContext(ctx, args.Username, args.Token, args.GraphID)
 	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to get webhook list: %v", err))
+		return s.createAPIErrorResult("Failed to get the current notification", err)
 	}
-
-	var webhooksData []map[string]interface{}
-	for _, webhook := range webhooksResponse.Webhooks {
-		webhookData := map[string]interface{}{
-			"webhookHash": webhook.WebhookHash,
-			"graphID":     webhook.GraphID,
-			"type":        webhook.Type,
-		}
-		if webhook.Quantity != "" {
-			webhookData["quantity"] = webhook.Quantity
+	var current *pixela.Notification
+	for i, notification := range notificationsResponse.Notifications {
+		if notification.ID == args.NotificationID {
+			current = &notificationsResponse.Notifications[i]
+			break
 		}
-		webhooksData = append(webhooksData, webhookData)
 	}
-
-	return s.createSuccessResult(fmt.Sprintf("%d webhooks retrieved", len(webhooksResponse.Webhooks)), webhooksData)
-}
-
-func (s *MCPServer) handleInvokeWebhook(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+	if current == nil {
+		return s.toolErrorResult(fmt.Sprintf("Notification '%s' not found on graph '%s'", args.NotificationID, args.GraphID), ToolError{Code: "not_found"})
 	}
 
-	webhookHash, ok := args["webhookHash"].(string)
-	if !ok {
-		return s.createErrorResult("webhookHash parameter is required")
+	// Pixela replaces the whole rule, so send the current value of every
+	// setting that was not given
+	req := pixela.UpdateNotificationRequest{
+		Name:      current.Name,
+		Target:    current.Target,
+		Condition: current.Condition,
+		Threshold: current.Threshold.String(),
+		RemindBy:  current.RemindBy,
+		ChannelID: current.ChannelID,
 	}
-
-	resp, err := client.InvokeWebhook(username, webhookHash)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to invoke webhook: %v", err))
+	if args.Name != nil {
+		req.Name = *args.Name
 	}
-
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Webhook '%s' executed successfully", webhookHash))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to invoke webhook: %s", resp.Message))
+	if args.Target != nil {
+		req.Target = *args.Target
 	}
-}
-
-func (s *MCPServer) handleDeleteWebhook(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
+	if args.Condition != nil {
+		req.Condition = *args.Condition
 	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
+	if args.Threshold != nil {
+		req.Threshold = *args.Threshold
 	}
-	webhookHash, ok := args["webhookHash"].(string)
-	if !ok {
-		return s.createErrorResult("webhookHash parameter is required")
+	if args.RemindBy != nil {
+		req.RemindBy = *args.RemindBy
 	}
-	resp, err := client.DeleteWebhook(username, token, webhookHash)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to delete webhook: %v", err))
+	if args.ChannelID != nil {
+		req.ChannelID = *args.ChannelID
 	}
-	if resp.IsSuccess {
-		return s.createSuccessResult(fmt.Sprintf("Webhook '%s' deleted successfully", webhookHash))
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to delete webhook: %s", resp.Message))
+
+	if _, err := client.UpdateNotificationContext(ctx, args.Username, args.Token, args.GraphID, args.NotificationID, req); err != nil {
+		return s.createAPIErrorResult("Failed to update notification", err)
 	}
+
+	return s.createSuccessResult(fmt.Sprintf("Notification '%s' of graph '%s' updated successfully", args.NotificationID, args.GraphID))
 }
 
-func (s *MCPServer) handleAddPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
-	quantity, ok := args["quantity"].(string)
-	if !ok {
-		return s.createErrorResult("quantity parameter is required")
+func (s *MCPServer) handleDeleteNotification(ctx context.Context, client *pixela.Client, args DeleteNotificationArgs) map[string]interface{} {
+	if result := s.confirmDestruction(ctx, "notificationID", args.NotificationID, args.Confirm, func(ctx context.Context) string {
+		return describeNotificationDeletion(ctx, client, args.NotificationArgs)
+	}); result != nil {
+		return result
 	}
 
-	resp, err := client.AddPixel(username, token, graphID, quantity)
-	if err != nil {
-		return s.createErrorResult(fmt.Sprintf("Failed to add pixel: %v", err))
+	if _, err := client.DeleteNotificationContext(ctx, args.Username, args.Token, args.GraphID, args.NotificationID); err != nil {
+		return s.createAPIErrorResult("Failed to delete notification", err)
 	}
 
-	if resp.IsSuccess {
-		return s.createSuccessResult("Today's pixel added successfully")
-	} else {
-		return s.createErrorResult(fmt.Sprintf("Failed to add pixel: %s", resp.Message))
-	}
+	return s.createSuccessResult(fmt.Sprintf("Notification '%s' of graph '%s' deleted successfully", args.NotificationID, args.GraphID))
 }
 
-func (s *MCPServer) handleSubtractPixel(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
-	quantity, ok := args["quantity"].(string)
-	if !ok {
-		return s.createErrorResult("quantity parameter is required")
+func (s *MCPServer) handleAddPixel(ctx context.Context, client *pixela.Client, args QuantityArgs) map[string]interface{} {
+	if _, err := client.AddPixelContext(ctx, args.Username, args.Token, args.GraphID, args.Quantity); err != nil {
+		return s.createAPIErrorResult("Failed to add pixel", err)
 	}
 
-	resp, err := client.SubtractPixel(username, token, graphID, quantity)
-	if err != nil {
-		return s.createErrorResult("Failed to subtract pixel: " + err.Error())
-	}
-	if !resp.IsSuccess {
-		return s.createErrorResult(resp.Message)
+	return s.createSuccessResult("Today's pixel added successfully")
+}
+
+func (s *MCPServer) handleSubtractPixel(ctx context.Context, client *pixela.Client, args QuantityArgs) map[string]interface{} {
+	if _, err := client.SubtractPixelContext(ctx, args.Username, args.Token, args.GraphID, args.Quantity); err != nil {
+		return s.createAPIErrorResult("Failed to subtract pixel", err)
 	}
 	return map[string]interface{}{
 		"content": []map[string]interface{}{
@@ -1070,26 +1304,9 @@ func (s *MCPServer) handleSubtractPixel(client *pixela.Client, args map[string]i
 	}
 }
 
-func (s *MCPServer) handleStopwatch(client *pixela.Client, args map[string]interface{}) map[string]interface{} {
-	username, ok := args["username"].(string)
-	if !ok {
-		return s.createErrorResult("username parameter is required")
-	}
-	token, ok := args["token"].(string)
-	if !ok {
-		return s.createErrorResult("token parameter is required")
-	}
-	graphID, ok := args["graphID"].(string)
-	if !ok {
-		return s.createErrorResult("graphID parameter is required")
-	}
-
-	resp, err := client.Stopwatch(username, token, graphID)
-	if err != nil {
-		return s.createErrorResult("Failed to call stopwatch: " + err.Error())
-	}
-	if !resp.IsSuccess {
-		return s.createErrorResult(resp.Message)
+func (s *MCPServer) handleStopwatch(ctx context.Context, client *pixela.Client, args GraphArgs) map[string]interface{} {
+	if _, err := client.StopwatchContext(ctx, args.Username, args.Token, args.GraphID); err != nil {
+		return s.createAPIErrorResult("Failed to call stopwatch", err)
 	}
 	return map[string]interface{}{
 		"content": []map[string]interface{}{
@@ -1098,6 +1315,32 @@ func (s *MCPServer) handleStopwatch(client *pixela.Client, args map[string]inter
 	}
 }
 
+func (s *MCPServer) handleListProfiles(ctx context.Context, client *pixela.Client, args ListProfilesArgs) map[string]interface{} {
+	output := ProfilesOutput{Profiles: []ProfileOutput{}}
+	var lines []string
+	for _, name := range s.config.Profiles.Names() {
+		profile, ok := s.config.Profiles.Lookup(name)
+		if !ok {
+			// Removed by a reload in the meantime
+			continue
+		}
+		output.Profiles = append(output.Profiles, ProfileOutput{
+			Name:     name,
+			Username: profile.Username,
+			BaseURL:  profile.BaseURL,
+		})
+		lines = append(lines, fmt.Sprintf("%s: %s", name, profile.Username))
+	}
+
+	if len(output.Profiles) == 0 {
+		return s.createSuccessResult("No profiles configured", output)
+	}
+	return s.createSuccessResult(fmt.Sprintf("%d profile(s):\n%s", len(output.Profiles), strings.Join(lines, "\n")), output)
+}
+
+// createSuccessResult returns message as text. When data is given it is
+// also serialized as JSON text, and returned as structuredContent to clients
+// that negotiated structured tool output.
 func (s *MCPServer) createSuccessResult(message string, data ...interface{}) map[string]interface{} {
 	content := []map[string]interface{}{
 		{
@@ -1106,19 +1349,47 @@ func (s *MCPServer) createSuccessResult(message string, data ...interface{}) map
 		},
 	}
 
+	result := map[string]interface{}{}
 	if len(data) > 0 && data[0] != nil {
-		content = append(content, map[string]interface{}{
-			"type": "json",
-			"json": data[0],
-		})
+		if jsonData, err := json.Marshal(data[0]); err == nil {
+			content = append(content, map[string]interface{}{
+				"type": "text",
+				"text": string(jsonData),
+			})
+		}
+		if s.protocolFeatures().StructuredOutput {
+			result["structuredContent"] = data[0]
+		}
 	}
 
-	return map[string]interface{}{
-		"content": content,
-	}
+	result["content"] = content
+	return result
+}
+
+// ToolError is the machine-readable description of a failed tool call,
+// returned in the result's _meta alongside the human-readable text.
+type ToolError struct {
+	// Code is one of invalid_argument, confirmation_required, not_found,
+	// unauthorized, rate_limited, pixela_error, cancelled, request_failed
+	// or render_failed.
+	Code          string `json:"code"`
+	HTTPStatus    int    `json:"httpStatus,omitempty"`
+	PixelaMessage string `json:"pixelaMessage,omitempty"`
+	Retryable     bool   `json:"retryable"`
 }
 
+// createErrorResult reports a problem with the arguments that the schema
+// could not catch.
 func (s *MCPServer) createErrorResult(message string) map[string]interface{} {
+	return s.toolErrorResult(message, ToolError{Code: "invalid_argument"})
+}
+
+// createAPIErrorResult reports a failed Pixela call, classifying err.
+func (s *MCPServer) createAPIErrorResult(message string, err error) map[string]interface{} {
+	return s.toolErrorResult(fmt.Sprintf("%s: %v", message, err), classifyError(err))
+}
+
+func (s *MCPServer) toolErrorResult(message string, toolErr ToolError) map[string]interface{} {
 	return map[string]interface{}{
 		"content": []map[string]interface{}{
 			{
@@ -1126,5 +1397,37 @@ func (s *MCPServer) createErrorResult(message string) map[string]interface{} {
 				"text": "Error: " + message,
 			},
 		},
+		"isError": true,
+		"_meta": map[string]interface{}{
+			"pixela-mcp/error": toolErr,
+		},
+	}
+}
+
+func classifyError(err error) ToolError {
+	if apiErr, ok := pixela.AsAPIError(err); ok {
+		toolErr := ToolError{
+			Code:          "pixela_error",
+			HTTPStatus:    apiErr.StatusCode,
+			PixelaMessage: apiErr.Message,
+			Retryable:     apiErr.Retryable(),
+		}
+		switch {
+		case pixela.IsNotFound(err):
+			toolErr.Code = "not_found"
+		case pixela.IsUnauthorized(err):
+			toolErr.Code = "unauthorized"
+		case pixela.IsRateLimited(err):
+			toolErr.Code = "rate_limited"
+		}
+		return toolErr
 	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ToolError{Code: "cancelled"}
+	}
+
+	// Transport failures are not flagged retryable: a mutating request may
+	// have reached Pixela before the connection failed
+	return ToolError{Code: "request_failed"}
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const testGraphSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="20" height="10"><rect width="10" height="10" fill="#216e39"/></svg>`
+
+func TestGraphSVGToolsTakeAnOptionalToken(t *testing.T) {
+	var gotPath, gotToken string
+	baseURL := newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotToken = r.URL.Path, r.Header.Get("X-USER-TOKEN")
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte(testGraphSVG))
+	})
+	plain := newTestSession(t, newTestConfig())
+	plain.initialize("2025-06-18", nil)
+	tools := listTools(plain, 1)
+	for _, name := range []string{"get_graph_svg", "render_graph_image"} {
+		if requiresProperty(tools[name], "token") || !requiresProperty(tools[name], "username") {
+			t.Errorf("%s inputSchema = %v, want username required and token optional", name, tools[name]["inputSchema"])
+		}
+	}
+
+	session := newTestSession(t, newProfileConfig(t, baseURL))
+	session.initialize("2025-06-18", nil)
+
+	tests := []struct {
+		name      string
+		tool      string
+		arguments map[string]interface{}
+		wantPath  string
+		wantToken string
+	}{
+		{"public graph of another user", "get_graph_svg", map[string]interface{}{"profile": "test", "username": "bob", "graphID": "g1"}, "/v1/users/bob/graphs/g1", ""},
+		{"explicit token", "get_graph_svg", map[string]interface{}{"profile": "test", "username": "bob", "token": "bobs-secret-token", "graphID": "g1"}, "/v1/users/bob/graphs/g1", "bobs-secret-token"},
+		{"profile token", "get_graph_svg", map[string]interface{}{"profile": "test", "graphID": "g1"}, "/v1/users/alice/graphs/g1", "secret-token"},
+		{"image of a public graph", "render_graph_image", map[string]interface{}{"profile": "test", "username": "bob", "graphID": "g1"}, "/v1/users/bob/graphs/g1", ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := session.call(i+2, tt.tool, tt.arguments)
+			if result["isError"] == true {
+				t.Fatalf("%s = %v, want success", tt.tool, result)
+			}
+			if gotPath != tt.wantPath || gotToken != tt.wantToken {
+				t.Errorf("Pixela got %s with token %q, want %s with token %q", gotPath, gotToken, tt.wantPath, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestUpdateNotificationKeepsAndClearsSettings(t *testing.T) {
+	var gotBody map[string]interface{}
+	baseURL := newFakePixela(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/users/alice/graphs/g1/notifications":
+			w.Write([]byte(`{"notifications":[{"id":"n1","name":"Walk","target":"quantity","condition":"<","threshold":"1","remindBy":"21","channelID":"slack"}]}`))
+		case "PUT /v1/users/alice/graphs/g1/notifications/n1":
+			gotBody = nil
+			if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+				t.Errorf("decoding the PUT body: %v", err)
+			}
+			w.Write([]byte(`{"message":"Success.","isSuccess":true}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	session := newTestSession(t, newProfileConfig(t, baseURL))
+	session.initialize("2025-06-18", nil)
+
+	current := map[string]interface{}{"name": "Walk", "target": "quantity", "condition": "<", "threshold": "1", "remindBy": "21", "channelID": "slack"}
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+	}{
+		{"nothing given", nil},
+		{"new threshold", map[string]interface{}{"threshold": "3"}},
+		{"cleared remindBy", map[string]interface{}{"remindBy": ""}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arguments := map[string]interface{}{"profile": "test", "graphID": "g1", "notificationID": "n1"}
+			want := make(map[string]interface{})
+			for k, v := range current {
+				want[k] = v
+			}
+			for k, v := range tt.settings {
+				arguments[k] = v
+				want[k] = v
+			}
+			if result := session.call(i+1, "update_notification", arguments); result["isError"] == true {
+				t.Fatalf("update_notification = %v, want success", result)
+			}
+			if !reflect.DeepEqual(gotBody, want) {
+				t.Errorf("PUT body = %v, want %v", gotBody, want)
+			}
+		})
+	}
+}
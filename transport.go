@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Transport delivers messages from the server to the client of one session.
+// Implementations must be safe for concurrent use.
+type Transport interface {
+	Send(message interface{}) error
+}
+
+type transportKey struct{}
+
+// withTransport returns a context whose server-initiated messages go through
+// t instead of the session's transport, e.g. the response stream of the
+// HTTP request being processed.
+func withTransport(ctx context.Context, t Transport) context.Context {
+	return context.WithValue(ctx, transportKey{}, t)
+}
+
+func transportFrom(ctx context.Context, fallback Transport) Transport {
+	if t, ok := ctx.Value(transportKey{}).(Transport); ok {
+		return t
+	}
+	return fallback
+}
+
+// stdioTransport writes newline-delimited JSON-RPC messages, one per line.
+type stdioTransport struct {
+	mu     sync.Mutex
+	writer *bufio.Writer
+}
+
+func newStdioTransport(w io.Writer) *stdioTransport {
+	return &stdioTransport{writer: bufio.NewWriter(w)}
+}
+
+func (t *stdioTransport) Send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return t.writer.Flush()
+}
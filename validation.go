@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidationError describes a single argument that does not match the
+// tool's input schema.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate checks value against the schema and returns every violation found,
+// so callers can report all offending fields at once.
+func (schema *JSONSchema) Validate(value interface{}) []ValidationError {
+	var errs []ValidationError
+	schema.validate("", value, &errs)
+	return errs
+}
+
+func (schema *JSONSchema) validate(path string, value interface{}, errs *[]ValidationError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch schema.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected string, got %s", jsonTypeName(value))
+			return
+		}
+		if len(schema.Enum) > 0 && !containsString(schema.Enum, str) {
+			fail("must be one of %s, got %q", strings.Join(schema.Enum, "/"), str)
+		}
+		if schema.patternRE != nil && !schema.patternRE.MatchString(str) {
+			fail("must match pattern %s, got %q", schema.Pattern, str)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %s", jsonTypeName(value))
+		}
+	case "integer":
+		num, ok := value.(float64)
+		if !ok || num != math.Trunc(num) {
+			fail("expected integer, got %s", jsonTypeName(value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("expected number, got %s", jsonTypeName(value))
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("expected array, got %s", jsonTypeName(value))
+			return
+		}
+		for i, item := range items {
+			schema.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %s", jsonTypeName(value))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, ValidationError{Field: joinPath(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				*errs = append(*errs, ValidationError{Field: joinPath(path, name), Message: "is not a known parameter"})
+				continue
+			}
+			prop.validate(joinPath(path, name), obj[name], errs)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidParamsError builds the JSON-RPC -32602 error returned when tool
+// arguments fail validation.
+func invalidParamsError(errs []ValidationError) *MCPError {
+	details := make([]string, 0, len(errs))
+	for _, e := range errs {
+		details = append(details, fmt.Sprintf("%s %s", e.Field, e.Message))
+	}
+	return &MCPError{
+		Code:    errCodeInvalidParams,
+		Message: "Invalid params: " + strings.Join(details, "; "),
+		Data: map[string]interface{}{
+			"errors": errs,
+		},
+	}
+}
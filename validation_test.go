@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	registry := newToolRegistry()
+	tests := []struct {
+		name      string
+		tool      string
+		arguments map[string]interface{}
+		want      []ValidationError
+	}{
+		{
+			name:      "valid",
+			tool:      "post_pixel",
+			arguments: map[string]interface{}{"username": "alice", "token": "secret-token", "graphID": "g1", "quantity": "5"},
+		},
+		{
+			name:      "missing required fields",
+			tool:      "post_pixel",
+			arguments: map[string]interface{}{"username": "alice"},
+			want: []ValidationError{
+				{Field: "token", Message: "is required"},
+				{Field: "graphID", Message: "is required"},
+				{Field: "quantity", Message: "is required"},
+			},
+		},
+		{
+			name:      "unknown parameter",
+			tool:      "get_graphs",
+			arguments: map[string]interface{}{"username": "alice", "token": "secret-token", "graph": "g1"},
+			want:      []ValidationError{{Field: "graph", Message: "is not a known parameter"}},
+		},
+		{
+			name: "enum violation",
+			tool: "create_graph",
+			arguments: map[string]interface{}{
+				"username": "alice", "token": "secret-token", "graphID": "g1",
+				"name": "Steps", "unit": "steps", "type": "int", "color": "green",
+			},
+			want: []ValidationError{{Field: "color", Message: `must be one of shibafu/momiji/sora/ichou/ajisai/kuro, got "green"`}},
+		},
+		{
+			name:      "pattern violation",
+			tool:      "get_pixel",
+			arguments: map[string]interface{}{"username": "alice", "token": "secret-token", "graphID": "g1", "date": "2024-01-01"},
+			want:      []ValidationError{{Field: "date", Message: `must match pattern ^[0-9]{8}$, got "2024-01-01"`}},
+		},
+		{
+			name:      "wrong types",
+			tool:      "get_pixels",
+			arguments: map[string]interface{}{"username": 42.0, "token": "secret-token", "graphID": true, "withBody": "yes"},
+			want: []ValidationError{
+				{Field: "graphID", Message: "expected string, got boolean"},
+				{Field: "username", Message: "expected string, got integer"},
+				{Field: "withBody", Message: "expected boolean, got string"},
+			},
+		},
+		{
+			name:      "null",
+			tool:      "get_graphs",
+			arguments: map[string]interface{}{"username": nil, "token": "secret-token"},
+			want:      []ValidationError{{Field: "username", Message: "expected string, got null"}},
+		},
+		{
+			name:      "number",
+			tool:      "render_graph_image",
+			arguments: map[string]interface{}{"username": "alice", "token": "secret-token", "graphID": "g1", "scale": "2"},
+			want:      []ValidationError{{Field: "scale", Message: "expected number, got string"}},
+		},
+		{
+			name: "array items",
+			tool: "batch_post_pixels",
+			arguments: map[string]interface{}{
+				"username": "alice", "token": "secret-token", "graphID": "g1",
+				"pixels": []interface{}{
+					map[string]interface{}{"date": "20240101", "quantity": "5"},
+					map[string]interface{}{"date": "20240102", "quantity": "five"},
+					map[string]interface{}{"quantity": "1"},
+					"20240104",
+				},
+			},
+			want: []ValidationError{
+				{Field: "pixels[1].quantity", Message: `must match pattern ^-?[0-9]+(\.[0-9]+)?$, got "five"`},
+				{Field: "pixels[2].date", Message: "is required"},
+				{Field: "pixels[3]", Message: "expected object, got string"},
+			},
+		},
+		{
+			name:      "not an array",
+			tool:      "batch_post_pixels",
+			arguments: map[string]interface{}{"username": "alice", "token": "secret-token", "graphID": "g1", "pixels": map[string]interface{}{}},
+			want:      []ValidationError{{Field: "pixels", Message: "expected array, got object"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, ok := registry.Lookup(tt.tool)
+			if !ok {
+				t.Fatalf("tool %s is not registered", tt.tool)
+			}
+			if got := tool.InputSchema.Validate(tt.arguments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolsCallReportsEveryInvalidArgument(t *testing.T) {
+	s := NewMCPServer(newToolRegistry(), &Config{Profiles: newProfileStore("")}, nil)
+
+	result, err := s.handleToolsCall(context.Background(), map[string]interface{}{
+		"name": "create_graph",
+		"arguments": map[string]interface{}{
+			"username": "alice", "token": "secret-token", "graphID": "g1",
+			"name": "Steps", "type": "integer", "color": "green",
+		},
+	})
+	if result != nil {
+		t.Fatalf("result = %v, want an error", result)
+	}
+	if err == nil || err.Code != errCodeInvalidParams {
+		t.Fatalf("error = %+v, want code %d", err, errCodeInvalidParams)
+	}
+
+	want := []ValidationError{
+		{Field: "unit", Message: "is required"},
+		{Field: "color", Message: `must be one of shibafu/momiji/sora/ichou/ajisai/kuro, got "green"`},
+		{Field: "type", Message: `must be one of int/float, got "integer"`},
+	}
+	data, _ := err.Data.(map[string]interface{})
+	if got := data["errors"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("error data = %+v, want %+v", got, want)
+	}
+	wantMessage := `Invalid params: unit is required; color must be one of shibafu/momiji/sora/ichou/ajisai/kuro, got "green"; type must be one of int/float, got "integer"`
+	if err.Message != wantMessage {
+		t.Errorf("error message = %q, want %q", err.Message, wantMessage)
+	}
+}